@@ -2,6 +2,8 @@ package shardcache
 
 import (
 	"context"
+	"io"
+	"iter"
 	"time"
 )
 
@@ -21,26 +23,124 @@ type Cache[K comparable, V any] interface {
 	// according to the active eviction policy (e.g., LRU).
 	Set(k K, v V)
 
+	// GetOrSet returns the value cached for k (loaded = true), or stores v
+	// like Set and returns it; atomic with respect to other writers of k.
+	GetOrSet(k K, v V) (actual V, loaded bool)
+
+	// Compute calls fn with the current value of k under the shard lock and
+	// applies the returned Op (OpSet, OpKeep, OpRemove) atomically. It returns
+	// the resulting value and whether k is present. fn must not call back
+	// into the cache.
+	Compute(k K, fn func(old V, ok bool) (V, Op)) (V, bool)
+
+	// CompareAndSwap replaces the value of k with v only if it currently
+	// equals old (Options.Equal, else ==).
+	CompareAndSwap(k K, old, v V) bool
+
 	// Get returns the value for k and a boolean flag indicating presence.
 	// On hit, the entry is promoted according to the policy.
 	Get(k K) (V, bool)
 
+	// GetWithVersion is Get that also returns the entry's version, which
+	// grows on every write of k. Use it with SetIfVersion/RemoveIfVersion for
+	// optimistic concurrency without holding locks across I/O.
+	GetWithVersion(k K) (V, uint64, bool)
+
+	// SetIfVersion stores v like Set only if k still has the given version
+	// (0 = k must be absent) and returns the new version.
+	SetIfVersion(k K, v V, version uint64) (uint64, bool)
+
+	// RemoveIfVersion removes k only if it still has the given version.
+	RemoveIfVersion(k K, version uint64) bool
+
+	// Peek returns the value for k without promoting it, extending a sliding
+	// TTL or touching hit/miss metrics (for admin tooling and health checks).
+	Peek(k K) (V, bool)
+
+	// Contains reports whether k is present, without side effects like Peek.
+	Contains(k K) bool
+
+	// Inspect returns metadata of the entry for k (remaining TTL, cost,
+	// version, age, policy segment) without side effects like Peek.
+	Inspect(k K) (EntryInfo, bool)
+
 	// Remove deletes k if present and returns true on success.
 	Remove(k K) bool
 
+	// All iterates over the live entries, copying one shard at a time (see
+	// the implementation notes on consistency). It does not promote entries.
+	All() iter.Seq2[K, V]
+
+	// Keys iterates over the keys of the live entries, like All.
+	Keys() iter.Seq[K]
+
+	// ByRecency iterates over up to n most recently used entries: exact MRU
+	// order within a shard, interleaved by rank across shards.
+	ByRecency(n int) iter.Seq2[K, V]
+
 	// Len returns the total number of resident entries across all shards.
 	Len() int
 
-	// Close stops background workers (if any) and marks the cache closed.
-	// Current implementation is a soft close and returns nil.
+	// Close stops background workers (if any) and marks the cache closed,
+	// waiting for the workers. Loader calls in flight are cancelled and not
+	// awaited; only results already being stored are. Afterwards
+	// error-returning methods return ErrClosed. It always returns nil.
+	// Close may be called from a Loader, but not from OnEvict or Cost.
 	Close() error
 
+	// Shutdown is Close with a deadline: it waits for loader calls in flight
+	// until ctx is done, then cancels them and returns ctx.Err(). With
+	// Options.EvictOnClose every entry is evicted through OnEvict.
+	Shutdown(ctx context.Context) error
+
 	// SetWithTTL inserts or updates k→v with a per-key TTL (relative duration).
 	// A non-positive ttl disables expiration for this entry.
 	SetWithTTL(k K, v V, ttl time.Duration)
 
-	// GetOrLoad returns the value for k, loading it via Options.Loader on miss.
-	// Concurrent loads for the same key are coalesced (singleflight).
+	// SetWithIdleTTL inserts or updates k→v with a sliding TTL: every hit
+	// extends the deadline to now+idle. A non-positive idle disables expiration.
+	SetWithIdleTTL(k K, v V, idle time.Duration)
+
+	// GetOrLoad returns the value for k, loading it via Options.Loader (or
+	// Options.LoaderWithInfo) on miss.
+	// Concurrent loads for the same key are coalesced (singleflight); the
+	// load is cancelled only when every waiting caller's ctx is done.
 	// If no Loader was configured, returns ErrNoLoader.
 	GetOrLoad(ctx context.Context, k K) (V, error)
+
+	// GetOrLoadAsync starts GetOrLoad without blocking and returns a channel
+	// that receives exactly one Result (then closed), so callers can select
+	// over several loads and their own timeouts.
+	GetOrLoadAsync(ctx context.Context, k K) <-chan Result[V]
+
+	// GetOrLoadMany returns values for keys, loading all misses with one
+	// Options.BulkLoader call (dataloader style). Misses already in flight in
+	// GetOrLoad are awaited rather than reloaded. Per-key failures are
+	// returned as LoadErrors[K] together with the values that succeeded.
+	GetOrLoadMany(ctx context.Context, keys []K) (map[K]V, error)
+
+	// Refresh forces a reload of k via the loader and stores the result.
+	// On failure the cached value is kept and the error is returned.
+	Refresh(ctx context.Context, k K) (V, error)
+
+	// Resize changes Capacity and MaxCost at runtime without dropping the
+	// warm contents. Shrinking evicts through the active policy (OnEvict is
+	// called) until the new limits are met. Policy parameters chosen at
+	// construction (e.g. 2Q queue sizes) are not changed.
+	Resize(capacity int, maxCost int64)
+
+	// Snapshot writes the live entries (with remaining TTL, cost and
+	// approximate per-shard recency order) to w using Options.KeyCodec and
+	// Options.ValueCodec. The stream is versioned and checksummed.
+	Snapshot(w io.Writer) error
+
+	// Restore loads a stream written by Snapshot into the cache. Corrupt or
+	// incompatible input is rejected (ErrSnapshotCorrupt, ErrSnapshotVersion,
+	// ErrSnapshotCodec) before any entry is inserted.
+	Restore(r io.Reader) error
+
+	// Stats returns cumulative counters (hits, misses, evictions by reason,
+	// removes, loads) as totals plus a per-shard breakdown. It is independent
+	// of Options.Metrics.
+	Stats() Stats
 }
@@ -0,0 +1,96 @@
+package shardcache
+
+import "sync/atomic"
+
+// budget tracks cache-wide resident cost and entry count so that MaxCost
+// (and, with Options.GlobalCapacity, Capacity) are enforced across all shards
+// rather than as a fixed per-shard split. Shards update it on every link and
+// unlink; the cache trims the heaviest shard while the budget is exceeded.
+//
+// A nil *budget means no cross-shard limit is configured.
+type budget struct {
+	maxCost atomic.Int64 // 0 = no cost limit
+	maxLen  atomic.Int64 // 0 = entry count limited per shard only
+
+	cost atomic.Int64
+	len  atomic.Int64
+}
+
+// add applies a delta to the totals.
+func (b *budget) add(dlen int, dcost int64) {
+	if dlen != 0 {
+		b.len.Add(int64(dlen))
+	}
+	if dcost != 0 {
+		b.cost.Add(dcost)
+	}
+}
+
+// over reports whether a limit is exceeded. Cost is checked first; byCost
+// tells the caller which dimension to rebalance on.
+func (b *budget) over() (byCost, exceeded bool) {
+	if max := b.maxCost.Load(); max > 0 && b.cost.Load() > max {
+		return true, true
+	}
+	if max := b.maxLen.Load(); max > 0 && b.len.Load() > max {
+		return false, true
+	}
+	return false, false
+}
+
+// enforceBudget evicts policy victims from the heaviest shard until the
+// cache-wide budget is satisfied. It takes one shard lock at a time and must
+// be called without any shard lock held.
+func (c *cache[K, V]) enforceBudget() {
+	b := c.budget.Load()
+	if b == nil {
+		return
+	}
+	for {
+		byCost, exceeded := b.over()
+		if !exceeded {
+			return
+		}
+		if !c.heaviestShard(byCost).trimBudget(b) {
+			return
+		}
+	}
+}
+
+// heaviestShard returns the shard with the largest resident cost (byCost) or
+// entry count. Values are read from per-shard gauges without locking.
+func (c *cache[K, V]) heaviestShard(byCost bool) *shard[K, V] {
+	best, bestVal := c.shards[0], int64(-1)
+	for _, s := range c.shards {
+		v := s.lenGauge.Load()
+		if byCost {
+			v = s.costGauge.Load()
+		}
+		if v > bestVal {
+			best, bestVal = s, v
+		}
+	}
+	return best
+}
+
+// trimBudget evicts one policy victim from s if the budget is still exceeded.
+// Returns false if there was nothing to do (or nothing to evict).
+func (s *shard[K, V]) trimBudget(b *budget) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	byCost, exceeded := b.over()
+	if !exceeded {
+		return false
+	}
+	reason := EvictPolicy
+	if byCost {
+		reason = EvictCapacity
+	}
+	if !s.evictVictimLocked(reason) {
+		return false
+	}
+	s.opt.Metrics.Size(s.len, s.cost)
+	return true
+}
@@ -0,0 +1,239 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IvanBrykalov/shardcache/internal/singleflight"
+)
+
+// LoadErrors reports per-key failures from GetOrLoadMany.
+// Keys absent from the map were loaded (or found) successfully. Under
+// Options.StaleIfError a failed key may still have a (stale) value; its error
+// then wraps ErrStale.
+type LoadErrors[K comparable] map[K]error
+
+// Error summarizes the failures; use the map for per-key details.
+func (e LoadErrors[K]) Error() string {
+	msgs := make([]string, 0, len(e))
+	for k, err := range e {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", k, err))
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("cache: %d keys failed to load: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// GetOrLoadMany returns the values for keys, resolving all misses with a
+// single Options.BulkLoader call. Misses already being loaded by another
+// caller (GetOrLoad or GetOrLoadMany) are awaited instead of reloaded. As
+// with GetOrLoad, the bulk load runs under a context detached from ctx that is
+// cancelled only once every caller waiting for one of its keys has given up.
+//
+// Keys the bulk loader omits from its result fail with ErrNotFound; if the
+// loader returns an error, every key it was asked for fails with it. A
+// panicking loader fails them with an error wrapping ErrLoaderPanic.
+// Per-key failures are returned as LoadErrors alongside the values that did
+// load. Without a BulkLoader, misses fall back to the single-key loader one
+// by one. Negatively cached failures are reported without asking either loader.
+// Without a Loader, hits due for a background reload (SWR, RefreshAfter,
+// XFetch) are refreshed together with one BulkLoader call.
+func (c *cache[K, V]) GetOrLoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	out := make(map[K]V, len(keys))
+	if c.closed.Load() {
+		return out, ErrClosed
+	}
+	errs := make(LoadErrors[K])
+	var misses []K
+	var refresh []K   // background reloads due without a Loader
+	var stale map[K]V // StaleIfError fallbacks
+	seen := make(map[K]struct{}, len(keys))
+	for _, k := range keys {
+		if _, dup := seen[k]; dup {
+			continue
+		}
+		seen[k] = struct{}{}
+		s := c.getShard(k)
+		switch v, st, revalidate := s.lookup(k); st {
+		case lookupFresh, lookupStale:
+			switch {
+			case !revalidate:
+			case c.loader != nil:
+				c.revalidate(k)
+			default:
+				refresh = append(refresh, k)
+			}
+			out[k] = v
+			continue
+		case lookupExpired:
+			if stale == nil {
+				stale = make(map[K]V)
+			}
+			stale[k] = v
+		}
+		if err := s.negative(k); err != nil {
+			errs[k] = err
+			continue
+		}
+		misses = append(misses, k)
+	}
+	if len(refresh) > 0 {
+		c.revalidateMany(refresh)
+	}
+
+	switch {
+	case len(misses) == 0:
+	case c.opt.BulkLoader != nil:
+		c.loadMany(ctx, misses, out, errs)
+	case c.loader != nil:
+		for _, k := range misses {
+			if v, err := c.sf.Do(ctx, k, c.loadFn(k)); err != nil {
+				errs[k] = err
+			} else {
+				out[k] = v
+			}
+		}
+	default:
+		return out, ErrNoLoader
+	}
+	for k, v := range stale {
+		if err, failed := errs[k]; failed {
+			if v, err = serveStale(v, err); !errors.Is(err, ErrNotFound) {
+				out[k] = v
+			}
+			errs[k] = err
+		}
+	}
+	if len(errs) > 0 {
+		return out, errs
+	}
+	return out, nil
+}
+
+// loadMany claims the misses in the singleflight group and bulk-loads the
+// owned ones in a new goroutine, detached from ctx like a GetOrLoad flight:
+// the batch is cancelled only once ctx is done and every other caller waiting
+// for one of its keys has given up. It then waits for all of the misses,
+// including the ones other callers are already loading.
+func (c *cache[K, V]) loadMany(ctx context.Context, misses []K, out map[K]V, errs LoadErrors[K]) {
+	bctx, owned, joined := c.sf.Claim(ctx, misses)
+	calls := joined
+	if len(owned) > 0 {
+		for k, call := range owned {
+			calls[k] = call
+		}
+		go func() {
+			defer func() {
+				// Never leave claimed keys in flight if a callback
+				// (Cost, OnEvict) panics.
+				if r := recover(); r != nil {
+					err := &singleflight.PanicError{Value: r, Stack: debug.Stack()}
+					for k, call := range owned {
+						var zero V
+						c.sf.Resolve(k, call, zero, err)
+					}
+				}
+			}()
+			c.loadOwned(bctx, owned)
+		}()
+	}
+
+	for k, call := range calls {
+		if v, err := call.Wait(ctx); err != nil {
+			errs[k] = err
+		} else {
+			out[k] = v
+		}
+	}
+}
+
+// loadOwned bulk-loads the keys loadMany claimed, stores each result (or
+// caches the failure) and resolves its call, deleting it from owned. Like
+// load, the batch is tracked for Shutdown until every result is stored.
+func (c *cache[K, V]) loadOwned(ctx context.Context, owned map[K]*singleflight.Call[V]) {
+	ks := make([]K, 0, len(owned))
+	for k := range owned {
+		ks = append(ks, k)
+	}
+	var vals map[K]V
+	var d time.Duration
+	ctx, done, err := c.loads.start(ctx)
+	if err == nil {
+		defer done()
+		vals, d, err = c.bulkLoad(ctx, ks)
+	}
+	for _, k := range ks {
+		v, ok := vals[k]
+		kerr := err
+		if kerr == nil && !ok {
+			kerr = ErrNotFound
+		}
+		if kerr == nil {
+			c.store(k, v, LoadInfo{}, d)
+		} else if !errors.Is(kerr, ErrLoaderPanic) {
+			c.cacheLoadError(ctx, k, kerr)
+		}
+		c.sf.Resolve(k, owned[k], v, kerr)
+		delete(owned, k)
+	}
+}
+
+// revalidateMany is revalidate for a cache without a Loader: keys are
+// reloaded in the background with one BulkLoader call, each accounted as a
+// refresh. Without a BulkLoader only their revalidation marks are cleared.
+func (c *cache[K, V]) revalidateMany(keys []K) {
+	go func() {
+		ks := make([]K, 0, len(keys))
+		for _, k := range keys {
+			if s := c.getShard(k); c.opt.BulkLoader == nil || s.negative(k) != nil {
+				s.endRevalidate(k)
+			} else {
+				ks = append(ks, k)
+			}
+		}
+		if len(ks) == 0 {
+			return
+		}
+		errs := make(LoadErrors[K])
+		c.loadMany(context.Background(), ks, make(map[K]V, len(ks)), errs)
+		for _, k := range ks {
+			s := c.getShard(k)
+			s.recordRefresh(errs[k])
+			s.endRevalidate(k)
+		}
+	}()
+}
+
+// bulkLoad calls Options.BulkLoader (one slot of the concurrency limit and
+// one breaker outcome per batch) and accounts it per key: every key's shard
+// records one load with the batch latency, which is also returned. A panic in
+// the loader is recovered and returned as a *singleflight.PanicError, like a
+// panicking Loader.
+func (c *cache[K, V]) bulkLoad(ctx context.Context, keys []K) (vals map[K]V, d time.Duration, err error) {
+	release, err := c.gate.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { release(ctx, err) }()
+	defer func() {
+		if r := recover(); r != nil {
+			vals, err = nil, &singleflight.PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	start := time.Now()
+	vals, err = c.opt.BulkLoader(ctx, keys)
+	d = time.Since(start)
+	for _, k := range keys {
+		kerr := err
+		if _, ok := vals[k]; kerr == nil && !ok {
+			kerr = ErrNotFound
+		}
+		c.getShard(k).recordLoad(d, kerr)
+	}
+	return vals, d, err
+}
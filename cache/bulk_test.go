@@ -0,0 +1,295 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Misses are resolved with one bulk call; omitted keys report ErrNotFound;
+// loaded values are cached.
+func TestGetOrLoadMany_Bulk(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var batches [][]int
+	c := New[int, string](Options[int, string]{
+		Capacity: 64,
+		BulkLoader: func(_ context.Context, keys []int) (map[int]string, error) {
+			mu.Lock()
+			batches = append(batches, append([]int(nil), keys...))
+			mu.Unlock()
+			out := make(map[int]string)
+			for _, k := range keys {
+				if k != 404 {
+					out[k] = "v"
+				}
+			}
+			return out, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set(1, "cached")
+	got, err := c.GetOrLoadMany(context.Background(), []int{1, 2, 3, 3, 404})
+
+	var lerr LoadErrors[int]
+	if !errors.As(err, &lerr) || len(lerr) != 1 || !errors.Is(lerr[404], ErrNotFound) {
+		t.Fatalf("want ErrNotFound for 404 only, got %v", err)
+	}
+	if len(got) != 3 || got[1] != "cached" || got[2] != "v" || got[3] != "v" {
+		t.Fatalf("unexpected values: %v", got)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("want one bulk call, got %d", len(batches))
+	}
+	sort.Ints(batches[0])
+	if len(batches[0]) != 3 || batches[0][0] != 2 || batches[0][2] != 404 {
+		t.Fatalf("bulk call must contain only the deduplicated misses, got %v", batches[0])
+	}
+	if v, ok := c.Get(2); !ok || v != "v" {
+		t.Fatal("bulk-loaded values must be cached")
+	}
+}
+
+// A key already being loaded by GetOrLoad is awaited, not bulk-loaded again.
+func TestGetOrLoadMany_CoalescesWithGetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var bulkKeys []string
+	c := New[string, string](Options[string, string]{
+		Capacity: 64,
+		Loader: func(_ context.Context, k string) (string, error) {
+			close(started)
+			<-release
+			return "single:" + k, nil
+		},
+		BulkLoader: func(_ context.Context, keys []string) (map[string]string, error) {
+			mu.Lock()
+			bulkKeys = append(bulkKeys, keys...)
+			mu.Unlock()
+			out := make(map[string]string)
+			for _, k := range keys {
+				out[k] = "bulk:" + k
+			}
+			return out, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	go func() { _, _ = c.GetOrLoad(context.Background(), "x") }()
+	<-started
+
+	done := make(chan map[string]string)
+	go func() {
+		got, err := c.GetOrLoadMany(context.Background(), []string{"x", "y"})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- got
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	got := <-done
+
+	if got["x"] != "single:x" || got["y"] != "bulk:y" {
+		t.Fatalf("unexpected values: %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bulkKeys) != 1 || bulkKeys[0] != "y" {
+		t.Fatalf("x is in flight and must not be bulk-loaded, got %v", bulkKeys)
+	}
+}
+
+// A panicking BulkLoader fails the caller and the joined waiters with an
+// error wrapping ErrLoaderPanic instead of crashing the caller.
+func TestGetOrLoadMany_BulkLoaderPanic(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c := New[string, string](Options[string, string]{
+		Capacity: 64,
+		BulkLoader: func(context.Context, []string) (map[string]string, error) {
+			close(started)
+			<-release
+			panic("boom")
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	type result struct {
+		got map[string]string
+		err error
+	}
+	owner := make(chan result)
+	go func() {
+		got, err := c.GetOrLoadMany(context.Background(), []string{"x", "y"})
+		owner <- result{got, err}
+	}()
+	<-started
+
+	joined := make(chan result)
+	go func() {
+		got, err := c.GetOrLoadMany(context.Background(), []string{"x"})
+		joined <- result{got, err}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for name, ch := range map[string]chan result{"owner": owner, "joined": joined} {
+		r := <-ch
+		var lerr LoadErrors[string]
+		if !errors.As(r.err, &lerr) || !errors.Is(lerr["x"], ErrLoaderPanic) {
+			t.Fatalf("%s: want ErrLoaderPanic for x, got %v", name, r.err)
+		}
+		if len(r.got) != 0 {
+			t.Fatalf("%s: want no values, got %v", name, r.got)
+		}
+	}
+	if c.Contains("x") || c.Contains("y") {
+		t.Fatal("nothing must be cached after a panic")
+	}
+}
+
+// Without a Loader, a due RefreshAfter reload goes through the BulkLoader and
+// the entry can be refreshed again afterwards.
+func TestGetOrLoadMany_RefreshAfterBulkOnly(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	calls := 0
+	clk := &fakeClock{}
+	c := New[string, int](Options[string, int]{
+		Capacity:     8,
+		Shards:       1,
+		RefreshAfter: time.Minute,
+		Clock:        clk,
+		BulkLoader: func(_ context.Context, keys []string) (map[string]int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			out := make(map[string]int)
+			for _, k := range keys {
+				out[k] = calls
+			}
+			return out, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	for want := 1; want <= 3; want++ {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got, err := c.GetOrLoadMany(ctx, []string{"k"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["k"] == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("want refresh %d, got %v", want, got)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		clk.add(2 * time.Minute)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Refreshes != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want two refreshes, got %+v", c.Stats().ShardStats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Cancelling the GetOrLoadMany caller that owns a batch does not fail the
+// GetOrLoad callers waiting for one of its keys.
+func TestGetOrLoadMany_OwnerCancelKeepsFollowers(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := New[string, string](Options[string, string]{
+		Capacity: 64,
+		Loader: func(context.Context, string) (string, error) {
+			return "single", nil
+		},
+		BulkLoader: func(ctx context.Context, keys []string) (map[string]string, error) {
+			close(started)
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			out := make(map[string]string)
+			for _, k := range keys {
+				out[k] = "bulk"
+			}
+			return out, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	owner := make(chan error)
+	go func() {
+		_, err := c.GetOrLoadMany(ctx, []string{"x"})
+		owner <- err
+	}()
+	<-started
+
+	follower := make(chan string)
+	go func() {
+		v, err := c.GetOrLoad(context.Background(), "x")
+		if err != nil {
+			t.Error(err)
+		}
+		follower <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	var lerr LoadErrors[string]
+	if err := <-owner; !errors.As(err, &lerr) || !errors.Is(lerr["x"], context.Canceled) {
+		t.Fatalf("owner: want context.Canceled for x, got %v", err)
+	}
+	close(release)
+	if v := <-follower; v != "bulk" {
+		t.Fatalf("follower: want the bulk-loaded value, got %q", v)
+	}
+}
+
+// Without a BulkLoader, each miss falls back to the Loader and is counted
+// once.
+func TestGetOrLoadMany_FallbackCountsMissesOnce(t *testing.T) {
+	t.Parallel()
+
+	c := New[int, int](Options[int, int]{
+		Capacity: 8,
+		Loader: func(_ context.Context, k int) (int, error) {
+			return k * 10, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set(1, 10)
+	got, err := c.GetOrLoadMany(context.Background(), []int{1, 2, 3})
+	if err != nil || len(got) != 3 || got[2] != 20 || got[3] != 30 {
+		t.Fatalf("unexpected values %v err=%v", got, err)
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 2 || st.Loads != 2 {
+		t.Fatalf("want 1 hit, 2 misses, 2 loads, got %+v", st.ShardStats)
+	}
+}
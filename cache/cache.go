@@ -2,8 +2,12 @@ package shardcache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"math/rand/v2"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,6 +19,22 @@ import (
 // ErrNoLoader is returned by GetOrLoad when no Loader was configured in Options.
 var ErrNoLoader = errorsNew("cache: no Loader provided")
 
+// ErrNotFound reports that a key does not exist in the backing store.
+// GetOrLoadMany returns it for keys the BulkLoader left out of its result;
+// a Loader may return (or wrap) it so the miss is cached for NegativeTTL.
+var ErrNotFound = errorsNew("cache: key not found")
+
+// ErrLoaderPanic is wrapped by the error returned to every caller waiting
+// for a load whose loader panicked.
+var ErrLoaderPanic = singleflight.ErrPanic
+
+// Result is the outcome of an asynchronous load (see GetOrLoadAsync).
+type Result[V any] = singleflight.Result[V]
+
+// ErrStale is wrapped (together with the load error) in the error returned
+// alongside a stale value served under Options.StaleIfError.
+var ErrStale = errorsNew("cache: stale value served")
+
 // lightweight local errors.New to avoid importing std 'errors' everywhere
 func errorsNew(s string) error { return &strErr{s} }
 
@@ -29,16 +49,39 @@ type cache[K comparable, V any] struct {
 	hash   func(K) uint64
 	closed atomic.Bool
 
+	// budget enforces MaxCost (and GlobalCapacity) across shards; nil if unset.
+	// It may be installed later by Resize, hence the atomic pointer.
+	budget atomic.Pointer[budget]
+
+	// resizeMu serializes Resize calls.
+	resizeMu sync.Mutex
+
 	opt Options[K, V]
+	// loader is Options.LoaderWithInfo, or Options.Loader adapted to it
+	// (nil if neither is set).
+	loader func(ctx context.Context, k K) (V, LoadInfo, error)
+	// gate limits concurrent loader calls and trips the circuit breaker
+	// (nil if neither is configured).
+	gate *loadGate
 
 	// singleflight group for coalescing concurrent loads in GetOrLoad.
 	sf singleflight.Group[K, V]
+
+	// background workers (janitor); stop is closed by Close.
+	stop chan struct{}
+	wg   sync.WaitGroup
+	// loads tracks loader calls in flight for Shutdown.
+	loads *loadTracker
+	// storing is read-held by store; closing takes it to wait for the stores
+	// in progress, after which every store sees closed.
+	storing sync.RWMutex
 }
 
 // New constructs a cache with the provided Options.
 // Defaults:
 //   - nil Metrics  -> NoopMetrics
 //   - nil Policy   -> LRU
+//   - nil Hasher   -> seeded maphash (any comparable K)
 //   - Shards <= 0  -> auto, rounded up to the next power of two
 func New[K comparable, V any](opt Options[K, V]) Cache[K, V] {
 	if opt.Capacity <= 0 {
@@ -52,6 +95,10 @@ func New[K comparable, V any](opt Options[K, V]) Cache[K, V] {
 	if opt.Policy == nil {
 		opt.Policy = lru.New[K, V]()
 	}
+	// default Hasher: seeded maphash
+	if opt.Hasher == nil {
+		opt.Hasher = util.NewMaphash[K]()
+	}
 
 	// number of shards -> power of two
 	sh := opt.Shards
@@ -65,44 +112,81 @@ func New[K comparable, V any](opt Options[K, V]) Cache[K, V] {
 		sh = int(util.NextPow2(uint64(sh)))
 	}
 
+	// Cache-wide budget: MaxCost always spans all shards; Capacity does too
+	// with GlobalCapacity. A single shard may then use the whole budget.
+	var b *budget
+	if opt.MaxCost > 0 || opt.GlobalCapacity {
+		b = &budget{}
+		b.maxCost.Store(opt.MaxCost)
+		if opt.GlobalCapacity {
+			b.maxLen.Store(int64(opt.Capacity))
+		}
+	}
+
 	cs := make([]*shard[K, V], sh)
 	perShardCap := (opt.Capacity + sh - 1) / sh // split capacity evenly (ceil)
+	shardCap := perShardCap
+	if opt.GlobalCapacity {
+		shardCap = opt.Capacity
+	}
 	for i := 0; i < sh; i++ {
-		cs[i] = newShard[K, V](perShardCap, opt.Policy, opt)
+		cs[i] = newShard[K, V](shardCap, perShardCap, opt.MaxCost, b, opt.Policy, opt)
 	}
 
-	// return pointer-to-impl as the interface (avoids unexported-return lint)
-	return &cache[K, V]{
+	c := &cache[K, V]{
 		shards: cs,
-		hash:   util.Fnv64a[K], // fast non-crypto hash for sharding
-		opt:    opt,            // keep Options for TTL/Cost/Loader/Metrics
+		hash:   opt.Hasher,
+		opt:    opt, // keep Options for TTL/Cost/Loader/Metrics
+		stop:   make(chan struct{}),
+		loads:  newLoadTracker(),
+	}
+	c.budget.Store(b)
+	c.gate = newLoadGate(opt, c.now)
+	switch {
+	case opt.LoaderWithInfo != nil:
+		c.loader = opt.LoaderWithInfo
+	case opt.Loader != nil:
+		c.loader = func(ctx context.Context, k K) (V, LoadInfo, error) {
+			v, err := opt.Loader(ctx, k)
+			return v, LoadInfo{}, err
+		}
+	}
+	if opt.ExpireInterval > 0 {
+		c.wg.Add(1)
+		go c.janitor(opt.ExpireInterval)
 	}
+
+	// return pointer-to-impl as the interface (avoids unexported-return lint)
+	return c
 }
 
 // ---- Cache[K,V] implementation ----
 
-// Add inserts k→v only if absent, using DefaultTTL if set.
-// Returns false if the key already exists (no update is performed).
+// Add inserts k→v only if absent, using DefaultTTL (or ExpireAfterAccess)
+// if set. Returns false if the key already exists (no update is performed).
 func (c *cache[K, V]) Add(k K, v V) bool {
 	if c.closed.Load() {
 		return false
 	}
 	s := c.getShard(k)
-	ttl := c.defaultDeadline()
+	ttl, idle := c.defaultExpiry()
 	cost := c.costOf(v)
-	return s.Add(k, v, ttl, cost)
+	added := s.Add(k, v, ttl, idle, cost)
+	c.enforceBudget()
+	return added
 }
 
-// Set inserts or updates k→v, using DefaultTTL if set,
-// and promotes the entry according to the active policy.
+// Set inserts or updates k→v, using DefaultTTL (or ExpireAfterAccess) if
+// set, and promotes the entry according to the active policy.
 func (c *cache[K, V]) Set(k K, v V) {
 	if c.closed.Load() {
 		return
 	}
 	s := c.getShard(k)
-	ttl := c.defaultDeadline()
+	ttl, idle := c.defaultExpiry()
 	cost := c.costOf(v)
-	s.Set(k, v, ttl, cost)
+	s.Set(k, v, ttl, idle, cost)
+	c.enforceBudget()
 }
 
 // SetWithTTL inserts or updates k→v with a per-key TTL (relative duration).
@@ -113,7 +197,24 @@ func (c *cache[K, V]) SetWithTTL(k K, v V, ttl time.Duration) {
 	}
 	s := c.getShard(k)
 	cost := c.costOf(v)
-	s.Set(k, v, c.deadline(ttl), cost)
+	s.Set(k, v, c.deadline(c.jitter(ttl)), 0, cost)
+	c.enforceBudget()
+}
+
+// SetWithIdleTTL inserts or updates k→v with a sliding TTL: the entry
+// expires once it has not been read for idle. A non-positive idle disables
+// expiration for this entry.
+func (c *cache[K, V]) SetWithIdleTTL(k K, v V, idle time.Duration) {
+	if c.closed.Load() {
+		return
+	}
+	if idle < 0 {
+		idle = 0
+	}
+	s := c.getShard(k)
+	cost := c.costOf(v)
+	s.Set(k, v, c.deadline(idle), int64(idle), cost)
+	c.enforceBudget()
 }
 
 // Get returns the value for k and a presence flag.
@@ -143,42 +244,303 @@ func (c *cache[K, V]) Len() int {
 	return total
 }
 
-// Close marks the cache as closed. Future operations are ignored.
-// If background workers are added (TTL/SWR revalidation), they should stop here.
+// Close is Shutdown that does not wait for loads: it stops background
+// workers and waits for them, cancels the context of loader calls in flight
+// and waits only for results already being stored; whatever the loaders
+// return later is dropped. Afterwards writes are ignored, reads miss and
+// error-returning APIs return ErrClosed. Close is idempotent and always
+// returns nil.
 func (c *cache[K, V]) Close() error {
-	c.closed.Store(true)
+	c.beginClose()
+	c.loads.cancel()
+	c.endClose()
 	return nil
 }
 
-// GetOrLoad returns the value for k; on miss it loads via Options.Loader,
-// coalescing concurrent loads for the same key (singleflight).
-// If no Loader is configured, returns ErrNoLoader.
+// Resize changes the entry capacity and cost budget at runtime, keeping the
+// resident entries. Shrinking evicts policy victims (with OnEvict) until the
+// new limits hold; growing takes effect immediately. capacity must be > 0;
+// maxCost <= 0 disables cost limiting.
+func (c *cache[K, V]) Resize(capacity int, maxCost int64) {
+	if capacity <= 0 {
+		panic("Capacity must be > 0")
+	}
+	if maxCost < 0 {
+		maxCost = 0
+	}
+	c.resizeMu.Lock()
+	defer c.resizeMu.Unlock()
+
+	b := c.budget.Load()
+	if b == nil && maxCost > 0 {
+		// First cost limit: shards attach to the new budget one by one.
+		b = &budget{}
+		c.budget.Store(b)
+	}
+	if b != nil {
+		b.maxCost.Store(maxCost)
+		if c.opt.GlobalCapacity {
+			b.maxLen.Store(int64(capacity))
+		}
+	}
+
+	shardCap := (capacity + len(c.shards) - 1) / len(c.shards)
+	if c.opt.GlobalCapacity {
+		shardCap = capacity
+	}
+	for _, s := range c.shards {
+		s.resize(shardCap, maxCost, b)
+	}
+	c.enforceBudget()
+}
+
+// GetOrLoad returns the value for k; on miss it loads via Options.Loader
+// (or LoaderWithInfo), coalescing concurrent loads for the same key
+// (singleflight). If no loader is configured, returns ErrNoLoader.
+//
+// With Options.SWR, an entry that expired less than SWR ago is returned as is
+// and a single background reload replaces it once the Loader succeeds.
+// With NegativeTTL/ErrorTTL, a cached load failure is returned until it expires.
+// With StaleIfError, a failed reload of a recently expired entry returns the
+// stale value and an error wrapping ErrStale. With RefreshAfter, an entry
+// older than that is returned and reloaded once in the background.
 func (c *cache[K, V]) GetOrLoad(ctx context.Context, k K) (V, error) {
-	// fast path
-	if v, ok := c.Get(k); ok {
-		return v, nil
+	p := c.probe(k)
+	if p.done {
+		return p.val, p.err
 	}
-	if c.opt.Loader == nil {
+	if c.loader == nil {
 		var zero V
 		return zero, ErrNoLoader
 	}
+	return p.finish(c.sf.Do(ctx, k, c.loadFn(k)))
+}
+
+// GetOrLoadAsync is GetOrLoad without blocking: the returned channel
+// receives exactly one Result and is then closed. Hits are delivered
+// immediately; misses join the singleflight load for k. If ctx is done
+// first, the Result carries ctx.Err() (the load itself goes on while other
+// callers still wait for it).
+func (c *cache[K, V]) GetOrLoadAsync(ctx context.Context, k K) <-chan Result[V] {
+	p := c.probe(k)
+	if !p.done && c.loader == nil {
+		p.done, p.err = true, ErrNoLoader
+	}
+	if p.done {
+		ch := make(chan Result[V], 1)
+		ch <- Result[V]{Val: p.val, Err: p.err}
+		close(ch)
+		return ch
+	}
+
+	ch := c.sf.DoChan(ctx, k, c.loadFn(k))
+	if !p.hasStale {
+		return ch
+	}
+	out := make(chan Result[V], 1)
+	go func() {
+		r := <-ch
+		r.Val, r.Err = p.finish(r.Val, r.Err)
+		out <- r
+		close(out)
+	}()
+	return out
+}
+
+// probeResult is the non-blocking part of GetOrLoad for one key.
+type probeResult[V any] struct {
+	val  V
+	err  error
+	done bool // resolved from the cache; val/err are final
+
+	stale    V // StaleIfError fallback for the load
+	hasStale bool
+}
+
+// probe resolves k from the cache alone: fresh (or SWR-stale) entries and
+// negatively cached failures are final; otherwise the caller must load,
+// falling back to the StaleIfError value if present.
+func (c *cache[K, V]) probe(k K) (p probeResult[V]) {
+	if c.closed.Load() {
+		p.err, p.done = ErrClosed, true
+		return p
+	}
+	s := c.getShard(k)
+	switch v, st, revalidate := s.lookup(k); st {
+	case lookupFresh, lookupStale:
+		if revalidate {
+			c.revalidate(k)
+		}
+		p.val, p.done = v, true
+		return p
+	case lookupExpired:
+		p.stale, p.hasStale = v, true
+	}
+	if err := s.negative(k); err != nil {
+		p.val, p.err = p.finish(p.val, err)
+		p.done = true
+	}
+	return p
+}
+
+// finish applies the StaleIfError fallback to the outcome of a load.
+func (p probeResult[V]) finish(v V, err error) (V, error) {
+	if err != nil && p.hasStale {
+		return serveStale(p.stale, err)
+	}
+	return v, err
+}
 
-	// singleflight: exactly one real load for the key
-	return c.sf.Do(ctx, k, func() (V, error) {
-		// double-check after flight join
-		if v, ok := c.Get(k); ok {
+// loadFn returns the singleflight function that loads k: it re-checks the
+// cache, then calls the loader and stores the result (or caches the failure).
+func (c *cache[K, V]) loadFn(k K) func(ctx context.Context) (V, error) {
+	return func(ctx context.Context) (V, error) {
+		// double-check after flight join; the caller already counted the miss
+		if v, ok := c.Peek(k); ok {
 			return v, nil
 		}
-		v, err := c.opt.Loader(ctx, k)
-		if err == nil {
-			c.Set(k, v)
+		if err := c.getShard(k).negative(k); err != nil {
+			var zero V
+			return zero, err
 		}
-		return v, err
+		return c.load(ctx, k)
+	}
+}
+
+// Refresh reloads k via the loader even if it is cached and fresh, and
+// stores the result. On failure the cached value (if any) is kept and the
+// error is returned; negatively cached failures are bypassed. A load of k
+// already in flight is joined instead of starting another.
+func (c *cache[K, V]) Refresh(ctx context.Context, k K) (V, error) {
+	var zero V
+	if c.closed.Load() {
+		return zero, ErrClosed
+	}
+	if c.loader == nil {
+		return zero, ErrNoLoader
+	}
+	return c.sf.Do(ctx, k, func(ctx context.Context) (V, error) {
+		return c.reload(ctx, k)
 	})
 }
 
+// Stats returns a snapshot of hit/miss/eviction/load counters, as totals and
+// per shard.
+func (c *cache[K, V]) Stats() Stats {
+	st := Stats{Shards: make([]ShardStats, len(c.shards))}
+	for i, s := range c.shards {
+		st.Shards[i] = s.stats()
+		st.add(st.Shards[i])
+	}
+	return st
+}
+
 // ---- helpers ----
 
+// serveStale implements StaleIfError for a failed load: it returns the stale
+// value with an error wrapping ErrStale and err, unless err is ErrNotFound.
+func serveStale[V any](stale V, err error) (V, error) {
+	if errors.Is(err, ErrNotFound) {
+		var zero V
+		return zero, err
+	}
+	return stale, fmt.Errorf("%w: %w", ErrStale, err)
+}
+
+// revalidate reloads k in the background for SWR and RefreshAfter. The load
+// joins any flight already in progress for k and replaces the entry only on
+// success; it is skipped while a failure of k is negatively cached (backoff).
+// Without a Loader it goes through revalidateMany.
+func (c *cache[K, V]) revalidate(k K) {
+	if c.loader == nil {
+		c.revalidateMany([]K{k})
+		return
+	}
+	go func() {
+		s := c.getShard(k)
+		defer s.endRevalidate(k)
+		if s.negative(k) != nil {
+			return
+		}
+		_, _ = c.sf.Do(context.Background(), k, func(ctx context.Context) (V, error) {
+			return c.reload(ctx, k)
+		})
+	}()
+}
+
+// reload loads a cached k again and stores the result, keeping the current
+// entry on failure. It is accounted as a refresh rather than a miss.
+func (c *cache[K, V]) reload(ctx context.Context, k K) (V, error) {
+	v, err := c.load(ctx, k)
+	c.getShard(k).recordRefresh(err)
+	return v, err
+}
+
+// load calls the loader for k and stores the result (or caches the failure).
+// It is tracked for Shutdown until the result is stored, so that Shutdown
+// never evicts the cache underneath a store still in progress.
+func (c *cache[K, V]) load(ctx context.Context, k K) (V, error) {
+	ctx, done, err := c.loads.start(ctx)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	defer done()
+
+	v, info, d, err := c.callLoader(ctx, k)
+	if err == nil {
+		c.store(k, v, info, d)
+	} else {
+		c.cacheLoadError(ctx, k, err)
+	}
+	return v, err
+}
+
+// callLoader calls the configured loader (through the concurrency limit and
+// circuit breaker) and records its outcome and latency in the key's shard.
+func (c *cache[K, V]) callLoader(ctx context.Context, k K) (v V, info LoadInfo, d time.Duration, err error) {
+	release, err := c.gate.acquire(ctx)
+	if err != nil {
+		return v, info, 0, err
+	}
+	err = ErrLoaderPanic // what the gate sees if the loader panics
+	defer func() { release(ctx, err) }()
+
+	start := time.Now()
+	v, info, err = c.loader(ctx, k)
+	d = time.Since(start)
+	c.getShard(k).recordLoad(d, err)
+	return v, info, d, err
+}
+
+// store writes a loaded value as directed by info (see LoadInfo); d is the
+// load duration, kept for XFetch.
+func (c *cache[K, V]) store(k K, v V, info LoadInfo, d time.Duration) {
+	c.storing.RLock()
+	defer c.storing.RUnlock()
+	if c.closed.Load() {
+		return
+	}
+	s := c.getShard(k)
+	if info.NoCache {
+		s.discard(k)
+		return
+	}
+	var ttl, idle int64
+	switch {
+	case info.TTL > 0:
+		ttl = c.deadline(c.jitter(info.TTL))
+	case info.TTL == 0:
+		ttl, idle = c.defaultExpiry()
+	}
+	cost := c.costOf(v)
+	if info.Cost > 0 {
+		cost = clampCost(info.Cost)
+	}
+	s.set(k, v, ttl, idle, cost, int64(d))
+	c.enforceBudget()
+}
+
 // getShard picks a shard by hashing the key and masking with len-1.
 // len(c.shards) is guaranteed to be a power of two.
 func (c *cache[K, V]) getShard(k K) *shard[K, V] {
@@ -187,12 +549,26 @@ func (c *cache[K, V]) getShard(k K) *shard[K, V] {
 	return c.shards[idx]
 }
 
-// defaultDeadline returns an absolute deadline based on DefaultTTL.
-func (c *cache[K, V]) defaultDeadline() int64 {
+// defaultExpiry returns the deadline and sliding TTL for writes without an
+// explicit TTL: ExpireAfterAccess if set, else a jittered DefaultTTL.
+func (c *cache[K, V]) defaultExpiry() (ttl, idle int64) {
+	if c.opt.ExpireAfterAccess > 0 {
+		return c.deadline(c.opt.ExpireAfterAccess), int64(c.opt.ExpireAfterAccess)
+	}
 	if c.opt.DefaultTTL <= 0 {
-		return 0
+		return 0, 0
 	}
-	return c.deadline(c.opt.DefaultTTL)
+	return c.deadline(c.jitter(c.opt.DefaultTTL)), 0
+}
+
+// jitter shortens ttl by a random fraction in [0, TTLJitter) so that
+// deadlines set at the same time spread out.
+func (c *cache[K, V]) jitter(ttl time.Duration) time.Duration {
+	j := min(c.opt.TTLJitter, 1)
+	if j <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(rand.Float64()*j*float64(ttl))
 }
 
 // deadline converts a relative TTL into an absolute UnixNano deadline.
@@ -201,11 +577,15 @@ func (c *cache[K, V]) deadline(ttl time.Duration) int64 {
 	if ttl <= 0 {
 		return 0
 	}
-	now := time.Now().UnixNano()
+	return c.now() + int64(ttl)
+}
+
+// now returns the current time in UnixNano from Options.Clock, if set.
+func (c *cache[K, V]) now() int64 {
 	if c.opt.Clock != nil {
-		now = c.opt.Clock.NowUnixNano()
+		return c.opt.Clock.NowUnixNano()
 	}
-	return now + int64(ttl)
+	return time.Now().UnixNano()
 }
 
 // costOf computes the per-entry cost (clamped to int32 range).
@@ -213,7 +593,11 @@ func (c *cache[K, V]) costOf(v V) int32 {
 	if c.opt.Cost == nil {
 		return 0
 	}
-	iv := c.opt.Cost(v)
+	return clampCost(c.opt.Cost(v))
+}
+
+// clampCost maps a user-supplied cost into [0, MaxInt32].
+func clampCost(iv int) int32 {
 	if iv < 0 {
 		iv = 0
 	}
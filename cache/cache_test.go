@@ -2,11 +2,16 @@ package shardcache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/IvanBrykalov/shardcache/policy/twoq"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -136,3 +141,1103 @@ func TestCache_GetOrLoad_Singleflight(t *testing.T) {
 		t.Fatalf("second GetOrLoad failed: v=%q err=%v", v, err)
 	}
 }
+
+// GetOrLoadAsync starts loads without blocking; results are collected with
+// select, hits resolve immediately and a caller's timeout only affects it.
+func TestCache_GetOrLoadAsync(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var calls int64
+	c := New[string, string](Options[string, string]{
+		Capacity: 16,
+		Loader: func(_ context.Context, k string) (string, error) {
+			atomic.AddInt64(&calls, 1)
+			if k == "slow" {
+				<-release
+			}
+			return "v:" + k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("hit", "cached")
+	select {
+	case r := <-c.GetOrLoadAsync(context.Background(), "hit"):
+		if r.Err != nil || r.Val != "cached" {
+			t.Fatalf("hit: got %+v", r)
+		}
+	default:
+		t.Fatal("a hit must be delivered without waiting")
+	}
+
+	a := c.GetOrLoadAsync(context.Background(), "a")
+	b := c.GetOrLoadAsync(context.Background(), "b")
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case r := <-a:
+			got[r.Val] = r.Err == nil
+			a = nil
+		case r := <-b:
+			got[r.Val] = r.Err == nil
+			b = nil
+		case <-time.After(2 * time.Second):
+			t.Fatal("async loads did not complete")
+		}
+	}
+	if !got["v:a"] || !got["v:b"] {
+		t.Fatalf("unexpected results %v", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	timedOut := c.GetOrLoadAsync(ctx, "slow")
+	waiter := c.GetOrLoadAsync(context.Background(), "slow")
+	if r := <-timedOut; !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded, got %+v", r)
+	}
+	close(release)
+	if r := <-waiter; r.Err != nil || r.Val != "v:slow" {
+		t.Fatalf("remaining waiter must get the value, got %+v", r)
+	}
+	if _, open := <-waiter; open {
+		t.Fatal("channel must be closed after the result")
+	}
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Fatalf("want 3 loader calls (a, b, slow), got %d", n)
+	}
+}
+
+type traceKey struct{}
+
+// The load runs under a context detached from the first caller: cancelling
+// that caller does not fail the others, and request values are preserved.
+func TestCache_GetOrLoad_LeaderCancel(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var startOnce sync.Once
+	var trace atomic.Value
+	c := New[string, string](Options[string, string]{
+		Capacity: 8,
+		Loader: func(ctx context.Context, k string) (string, error) {
+			trace.Store(ctx.Value(traceKey{}))
+			startOnce.Do(func() { close(started) })
+			select {
+			case <-release:
+				return "v:" + k, nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	leaderCtx, cancelLeader := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "req-1"))
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(leaderCtx, "k")
+		leaderErr <- err
+	}()
+	<-started
+
+	follower := make(chan error, 1)
+	go func() {
+		v, err := c.GetOrLoad(context.Background(), "k")
+		if err == nil && v != "v:k" {
+			err = fmt.Errorf("got %q", v)
+		}
+		follower <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // let the follower join
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader: want context.Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-follower; err != nil {
+		t.Fatalf("follower must get the loaded value, got %v", err)
+	}
+	if got := trace.Load(); got != "req-1" {
+		t.Fatalf("loader ctx must keep the caller's values, got %v", got)
+	}
+}
+
+// Once every waiter has given up, the load's context is cancelled.
+func TestCache_GetOrLoad_AllWaitersGone(t *testing.T) {
+	t.Parallel()
+
+	cancelled := make(chan struct{})
+	c := New[string, string](Options[string, string]{
+		Capacity: 8,
+		Loader: func(ctx context.Context, _ string) (string, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return "", ctx.Err()
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var g errgroup.Group
+	for i := 0; i < 4; i++ {
+		g.Go(func() error {
+			if _, err := c.GetOrLoad(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("want DeadlineExceeded, got %v", err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("load was not cancelled after all waiters left")
+	}
+}
+
+// A panicking loader fails every waiter with ErrLoaderPanic instead of
+// leaving followers blocked, and the key can be loaded again afterwards.
+func TestCache_GetOrLoad_LoaderPanic(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	release := make(chan struct{})
+	c := New[string, string](Options[string, string]{
+		Capacity: 8,
+		Loader: func(_ context.Context, k string) (string, error) {
+			if atomic.AddInt64(&calls, 1) == 1 {
+				<-release // every caller has joined
+				panic("boom")
+			}
+			return "v:" + k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// GetOrLoadAsync joins the flight before it returns, so all 8 callers
+	// wait for the first load once the loop is done.
+	results := make([]<-chan Result[string], 8)
+	for i := range results {
+		results[i] = c.GetOrLoadAsync(ctx, "k")
+	}
+	close(release)
+	for i, ch := range results {
+		if r := <-ch; !errors.Is(r.Err, ErrLoaderPanic) {
+			t.Fatalf("caller %d: want ErrLoaderPanic, got %v", i, r.Err)
+		}
+	}
+	if v, err := c.GetOrLoad(ctx, "k"); err != nil || v != "v:k" {
+		t.Fatalf("want reload after panic, got %q err=%v", v, err)
+	}
+}
+
+// SWR: an expired entry inside the window is served immediately while a single
+// background reload replaces it.
+func TestCache_GetOrLoad_SWR(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:   8,
+		Shards:     1,
+		DefaultTTL: 100 * time.Millisecond,
+		SWR:        time.Second,
+		Clock:      clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			n := atomic.AddInt64(&calls, 1)
+			return fmt.Sprintf("%s:%d", k, n), nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("k", "old")
+	clk.add(200 * time.Millisecond) // expired, but inside SWR
+
+	for i := 0; i < 10; i++ {
+		v, err := c.GetOrLoad(context.Background(), "k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != "old" && v != "k:1" {
+			t.Fatalf("unexpected value %q", v)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, ok := c.Get("k"); ok && v == "k:1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background reload did not replace the stale entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("loader must run exactly once, got %d", got)
+	}
+
+	// Beyond the SWR window the entry is gone and GetOrLoad loads synchronously.
+	clk.add(2 * time.Second)
+	if v, err := c.GetOrLoad(context.Background(), "k"); err != nil || v != "k:2" {
+		t.Fatalf("want synchronous reload k:2, got %q err=%v", v, err)
+	}
+}
+
+// The janitor reclaims cold expired entries without any access and reports
+// them through OnEvict with EvictTTL; Close stops it.
+func TestCache_ExpireInterval_Janitor(t *testing.T) {
+	t.Parallel()
+
+	var ttlEvicts int64
+	c := New[int, int](Options[int, int]{
+		Capacity:       64,
+		ExpireInterval: 5 * time.Millisecond,
+		OnEvict: func(_ int, _ int, reason EvictReason) {
+			if reason == EvictTTL {
+				atomic.AddInt64(&ttlEvicts, 1)
+			}
+		},
+	})
+
+	for i := 0; i < 10; i++ {
+		c.SetWithTTL(i, i, 10*time.Millisecond)
+	}
+	c.Set(100, 100) // no TTL, must survive
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not reclaim expired entries, Len=%d", c.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := atomic.LoadInt64(&ttlEvicts); got != 10 {
+		t.Fatalf("want 10 EvictTTL callbacks, got %d", got)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil { // idempotent
+		t.Fatal(err)
+	}
+}
+
+// Close cancels loads in flight without waiting for them and drops their
+// results; afterwards the error-returning APIs report ErrClosed without
+// calling the loader.
+func TestCache_Close_CancelsLoads(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	var cancelled atomic.Bool
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := New[string, int](Options[string, int]{
+		Capacity: 8,
+		Loader: func(ctx context.Context, _ string) (int, error) {
+			if atomic.AddInt64(&calls, 1) == 1 {
+				close(started)
+			}
+			<-ctx.Done()
+			cancelled.Store(true)
+			<-release // a slow loader that returns after its cancellation
+			return 1, nil
+		},
+	})
+
+	loaded := make(chan struct{})
+	go func() {
+		_, _ = c.GetOrLoad(context.Background(), "a")
+		close(loaded)
+	}()
+	<-started
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	<-loaded
+	if !cancelled.Load() {
+		t.Fatal("Close must cancel the loads in flight")
+	}
+
+	if c.Len() != 0 {
+		t.Fatalf("a load finishing after Close must not be stored, Len=%d", c.Len())
+	}
+	ctx := context.Background()
+	if _, err := c.GetOrLoad(ctx, "b"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("GetOrLoad: want ErrClosed, got %v", err)
+	}
+	if r := <-c.GetOrLoadAsync(ctx, "b"); !errors.Is(r.Err, ErrClosed) {
+		t.Fatalf("GetOrLoadAsync: want ErrClosed, got %v", r.Err)
+	}
+	if _, err := c.GetOrLoadMany(ctx, []string{"b"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("GetOrLoadMany: want ErrClosed, got %v", err)
+	}
+	if _, err := c.Refresh(ctx, "b"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Refresh: want ErrClosed, got %v", err)
+	}
+	if err := c.Snapshot(io.Discard); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Snapshot: want ErrClosed, got %v", err)
+	}
+	if n := atomic.LoadInt64(&calls); n != 1 {
+		t.Fatalf("closed cache must not call the loader, calls=%d", n)
+	}
+}
+
+// Close may be called from a Loader: it does not wait for the load.
+func TestCache_Close_FromLoader(t *testing.T) {
+	t.Parallel()
+
+	var c Cache[string, int]
+	c = New[string, int](Options[string, int]{
+		Capacity: 8,
+		Loader: func(context.Context, string) (int, error) {
+			return 1, c.Close()
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = c.GetOrLoad(context.Background(), "a")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close called from a Loader deadlocked")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("the load must not be stored after Close, Len=%d", c.Len())
+	}
+}
+
+// Shutdown gives up at its deadline, cancelling the loads still running,
+// and with EvictOnClose releases every entry through OnEvict.
+func TestCache_Shutdown_DeadlineAndEvictOnClose(t *testing.T) {
+	t.Parallel()
+
+	var closedEvicts int64
+	started := make(chan struct{})
+	loadErr := make(chan error, 1)
+	c := New[string, int](Options[string, int]{
+		Capacity:     8,
+		EvictOnClose: true,
+		OnEvict: func(_ string, _ int, r EvictReason) {
+			if r == EvictClosed {
+				atomic.AddInt64(&closedEvicts, 1)
+			}
+		},
+		Loader: func(ctx context.Context, _ string) (int, error) {
+			close(started)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+	})
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, 1)
+	}
+
+	go func() {
+		_, err := c.GetOrLoad(context.Background(), "slow")
+		loadErr <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+	if err := <-loadErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Shutdown must cancel the remaining load, got %v", err)
+	}
+
+	if got := atomic.LoadInt64(&closedEvicts); got != 3 {
+		t.Fatalf("want 3 EvictClosed callbacks, got %d", got)
+	}
+	if c.Len() != 0 || c.Stats().Evictions[EvictClosed] != 3 {
+		t.Fatalf("want empty cache with 3 EvictClosed, Len=%d stats=%v", c.Len(), c.Stats().Evictions)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A load is awaited by Shutdown until its result is stored, so that
+// EvictOnClose also releases it.
+func TestCache_Shutdown_AwaitsStore(t *testing.T) {
+	t.Parallel()
+
+	var closedEvicts int64
+	storing := make(chan struct{})
+	release := make(chan struct{})
+	c := New[string, int](Options[string, int]{
+		Capacity:     8,
+		EvictOnClose: true,
+		OnEvict: func(_ string, _ int, r EvictReason) {
+			if r == EvictClosed {
+				atomic.AddInt64(&closedEvicts, 1)
+			}
+		},
+		// Cost runs inside store, after the loader has returned.
+		Cost: func(int) int {
+			close(storing)
+			<-release
+			return 1
+		},
+		Loader: func(context.Context, string) (int, error) { return 1, nil },
+	})
+
+	go func() { _, _ = c.GetOrLoad(context.Background(), "a") }()
+	<-storing
+
+	closed := make(chan error)
+	go func() { closed <- c.Shutdown(context.Background()) }()
+	select {
+	case <-closed:
+		t.Fatal("Shutdown returned while a loaded value was being stored")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Len() != 0 || atomic.LoadInt64(&closedEvicts) != 1 {
+		t.Fatalf("the stored value must be evicted on close, Len=%d evicts=%d", c.Len(), atomic.LoadInt64(&closedEvicts))
+	}
+}
+
+// Stats aggregates per-shard counters into totals.
+func TestCache_Stats(t *testing.T) {
+	t.Parallel()
+
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity: 2,
+		Shards:   1,
+		Clock:    clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			if k == "bad" {
+				return "", fmt.Errorf("boom")
+			}
+			return "v:" + k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("a", "1")
+	c.Get("a")       // hit
+	c.Get("missing") // miss
+	c.SetWithTTL("t", "x", time.Millisecond)
+	clk.add(time.Second)
+	c.Get("t") // miss + EvictTTL
+	c.Set("b", "2")
+	c.Set("c", "3") // capacity eviction (EvictPolicy)
+	c.Remove("c")
+	_, _ = c.GetOrLoad(context.Background(), "d")
+	_, _ = c.GetOrLoad(context.Background(), "bad")
+
+	st := c.Stats()
+	if len(st.Shards) != 1 {
+		t.Fatalf("want 1 shard, got %d", len(st.Shards))
+	}
+	if st.Hits != 1 {
+		t.Fatalf("hits: want 1, got %d", st.Hits)
+	}
+	// misses: missing, t, d and bad (each GetOrLoad miss counted once)
+	if st.Misses != 4 {
+		t.Fatalf("misses: want 4, got %d", st.Misses)
+	}
+	if st.Evictions[EvictTTL] != 1 || st.Evictions[EvictPolicy] < 1 {
+		t.Fatalf("evictions: %+v", st.Evictions)
+	}
+	if st.Removes != 1 {
+		t.Fatalf("removes: want 1, got %d", st.Removes)
+	}
+	if st.Loads != 2 || st.LoadErrors != 1 {
+		t.Fatalf("loads=%d errors=%d, want 2/1", st.Loads, st.LoadErrors)
+	}
+	if st.Len != c.Len() || !reflect.DeepEqual(st.Shards[0], st.ShardStats) {
+		t.Fatalf("totals must match the single shard: %+v vs %+v", st.ShardStats, st.Shards[0])
+	}
+}
+
+// Struct keys work with the default hasher; a custom Hasher is honored.
+func TestCache_Hasher(t *testing.T) {
+	t.Parallel()
+
+	type key struct {
+		tenant string
+		id     int
+	}
+	c := New[key, int](Options[key, int]{Capacity: 64})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 32; i++ {
+		c.Set(key{"t", i}, i)
+	}
+	for i := 0; i < 32; i++ {
+		if v, ok := c.Get(key{"t", i}); !ok || v != i {
+			t.Fatalf("key %d: got %v ok=%v", i, v, ok)
+		}
+	}
+
+	var calls int64
+	c2 := New[key, int](Options[key, int]{
+		Capacity: 8,
+		Hasher: func(k key) uint64 {
+			atomic.AddInt64(&calls, 1)
+			return uint64(k.id)
+		},
+	})
+	t.Cleanup(func() { _ = c2.Close() })
+
+	c2.Set(key{"t", 1}, 1)
+	if v, ok := c2.Get(key{"t", 1}); !ok || v != 1 {
+		t.Fatalf("custom hasher: got %v ok=%v", v, ok)
+	}
+	if atomic.LoadInt64(&calls) == 0 {
+		t.Fatal("custom Hasher was not used")
+	}
+}
+
+// Cost-limit evictions go through the policy: with 2Q, a one-hit entry in
+// A1in (below its quota) is kept and the LRU of Am is reclaimed instead of
+// whatever happens to be the shard list tail.
+func TestCache_CostLimit_UsesPolicyVictim(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{
+		Capacity: 100,
+		Shards:   1,
+		MaxCost:  3,
+		Cost:     func(v int) int { return v },
+		Policy:   twoq.New[string, int](3, 4),
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("b", 1) // A1in [b]
+	c.Set("a", 1) // A1in [a b]
+	c.Get("a")    // a -> Am; shard list tail is b
+	c.Set("c", 2) // A1in [c b], cost 4 > 3 -> reclaim from Am
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a (LRU of Am) must be evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("b (A1in below quota) must survive")
+	}
+}
+
+// MaxCost is a cache-wide budget: keys that all land in one shard may use
+// the whole budget, and the total never exceeds it.
+func TestCache_MaxCost_Global(t *testing.T) {
+	t.Parallel()
+
+	c := New[int, int](Options[int, int]{
+		Capacity: 1000,
+		Shards:   4,
+		MaxCost:  10,
+		Cost:     func(int) int { return 1 },
+		Hasher:   func(int) uint64 { return 0 }, // everything in shard 0
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 20; i++ {
+		c.Set(i, i)
+	}
+	if st := c.Stats(); st.Len != 10 || st.Cost != 10 {
+		t.Fatalf("want 10 entries / cost 10 in a single shard, got len=%d cost=%d", st.Len, st.Cost)
+	}
+
+	// Spread keys: the total must stay within budget.
+	c2 := New[int, int](Options[int, int]{
+		Capacity: 1000,
+		Shards:   4,
+		MaxCost:  10,
+		Cost:     func(v int) int { return v },
+	})
+	t.Cleanup(func() { _ = c2.Close() })
+	for i := 0; i < 100; i++ {
+		c2.Set(i, 1+i%3)
+		if st := c2.Stats(); st.Cost > 10 {
+			t.Fatalf("total cost %d exceeds MaxCost", st.Cost)
+		}
+	}
+}
+
+// GlobalCapacity lets one shard hold the whole Capacity.
+func TestCache_GlobalCapacity(t *testing.T) {
+	t.Parallel()
+
+	c := New[int, int](Options[int, int]{
+		Capacity:       10,
+		Shards:         4,
+		GlobalCapacity: true,
+		Hasher:         func(int) uint64 { return 0 },
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 20; i++ {
+		c.Set(i, i)
+	}
+	if got := c.Len(); got != 10 {
+		t.Fatalf("want Len 10, got %d", got)
+	}
+	// The most recent entries survive (LRU victims).
+	if _, ok := c.Get(19); !ok {
+		t.Fatal("most recent key must be resident")
+	}
+}
+
+// Resize shrinks through the policy (OnEvict fires) and can grow again,
+// including enabling a cost limit that was not configured at New.
+func TestCache_Resize(t *testing.T) {
+	t.Parallel()
+
+	var evicted int64
+	c := New[int, int](Options[int, int]{
+		Capacity: 100,
+		Shards:   4,
+		Cost:     func(int) int { return 2 },
+		Hasher:   func(k int) uint64 { return uint64(k) }, // round-robin shards
+		OnEvict:  func(int, int, EvictReason) { atomic.AddInt64(&evicted, 1) },
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	if c.Len() != 100 {
+		t.Fatalf("warm-up: want 100 entries, got %d", c.Len())
+	}
+
+	c.Resize(100, 40) // enable MaxCost: at most 20 entries of cost 2
+	if st := c.Stats(); st.Cost > 40 || st.Len > 20 {
+		t.Fatalf("after cost shrink: len=%d cost=%d", st.Len, st.Cost)
+	}
+
+	c.Resize(8, 0) // shrink capacity, disable cost limit
+	if got := c.Len(); got > 8 {
+		t.Fatalf("after capacity shrink: Len=%d > 8", got)
+	}
+	if got := atomic.LoadInt64(&evicted); got != int64(100-c.Len()) {
+		t.Fatalf("every dropped entry must go through OnEvict: evicted=%d len=%d", got, c.Len())
+	}
+
+	c.Resize(1000, 0) // grow: new entries are kept
+	for i := 0; i < 200; i++ {
+		c.Set(1000+i, i)
+	}
+	if got := c.Len(); got < 200 {
+		t.Fatalf("after grow: want >= 200 entries, got %d", got)
+	}
+}
+
+// LoaderWithInfo controls the TTL, cost and admission of each loaded value.
+func TestCache_GetOrLoad_LoadInfo(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:   8,
+		Shards:     1,
+		DefaultTTL: time.Hour,
+		MaxCost:    100,
+		Clock:      clk,
+		LoaderWithInfo: func(_ context.Context, k string) (string, LoadInfo, error) {
+			atomic.AddInt64(&calls, 1)
+			switch k {
+			case "short":
+				return "s", LoadInfo{TTL: 100 * time.Millisecond, Cost: 10}, nil
+			case "forever":
+				return "f", LoadInfo{TTL: -1}, nil
+			case "nocache":
+				return "n", LoadInfo{NoCache: true}, nil
+			}
+			return "d", LoadInfo{}, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	for _, k := range []string{"short", "forever", "nocache", "default"} {
+		if _, err := c.GetOrLoad(ctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.Get("nocache"); ok {
+		t.Fatal("NoCache value must not be stored")
+	}
+	c.Set("nocache", "old")
+	if _, err := c.Refresh(ctx, "nocache"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Contains("nocache") || c.Stats().Removes != 0 {
+		t.Fatalf("NoCache must drop the cached entry without counting a Remove, removes=%d", c.Stats().Removes)
+	}
+	if st := c.Stats(); st.Cost != 10 {
+		t.Fatalf("want loader-provided cost 10, got %d", st.Cost)
+	}
+
+	clk.add(200 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("entry must expire after the loader-provided TTL")
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Fatal("zero TTL must fall back to DefaultTTL")
+	}
+	clk.add(2 * time.Hour)
+	if _, ok := c.Get("forever"); !ok {
+		t.Fatal("negative TTL must disable expiration")
+	}
+	if _, ok := c.Get("default"); ok {
+		t.Fatal("DefaultTTL must apply")
+	}
+}
+
+// StaleIfError serves the last good value (flagged with ErrStale) when the
+// reload of an expired entry fails, until the window closes.
+func TestCache_GetOrLoad_StaleIfError(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("backend down")
+	var fail atomic.Bool
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:     8,
+		Shards:       1,
+		DefaultTTL:   100 * time.Millisecond,
+		StaleIfError: time.Second,
+		Clock:        clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			if fail.Load() {
+				return "", boom
+			}
+			return "new", nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("k", "old")
+	clk.add(200 * time.Millisecond)
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get must report an expired entry as a miss")
+	}
+
+	fail.Store(true)
+	v, err := c.GetOrLoad(context.Background(), "k")
+	if v != "old" || !errors.Is(err, ErrStale) || !errors.Is(err, boom) {
+		t.Fatalf("want stale value with ErrStale wrapping the load error, got %q err=%v", v, err)
+	}
+
+	fail.Store(false)
+	if v, err := c.GetOrLoad(context.Background(), "k"); err != nil || v != "new" {
+		t.Fatalf("want successful reload, got %q err=%v", v, err)
+	}
+
+	// Beyond the window the failure propagates.
+	fail.Store(true)
+	clk.add(2 * time.Second)
+	if v, err := c.GetOrLoad(context.Background(), "k"); v != "" || !errors.Is(err, boom) || errors.Is(err, ErrStale) {
+		t.Fatalf("want plain load error after the window, got %q err=%v", v, err)
+	}
+}
+
+// Entries older than RefreshAfter keep being served while one background
+// reload replaces them; refreshes are counted apart from misses.
+// minimalMetrics implements only the required Metrics methods.
+type minimalMetrics struct{}
+
+func (minimalMetrics) Hit()              {}
+func (minimalMetrics) Miss()             {}
+func (minimalMetrics) Evict(EvictReason) {}
+func (minimalMetrics) Size(int, int64)   {}
+
+type refreshRecorder struct {
+	minimalMetrics
+	ok, failed atomic.Int64
+}
+
+func (r *refreshRecorder) Refresh(ok bool) {
+	if ok {
+		r.ok.Add(1)
+	} else {
+		r.failed.Add(1)
+	}
+}
+
+// Refreshes reach Options.Metrics only if it implements RefreshMetrics; a
+// plain Metrics implementation still works.
+func TestCache_RefreshMetrics(t *testing.T) {
+	t.Parallel()
+
+	rec := &refreshRecorder{}
+	for _, m := range []Metrics{minimalMetrics{}, rec} {
+		c := New[string, string](Options[string, string]{
+			Capacity: 8,
+			Metrics:  m,
+			Loader: func(_ context.Context, k string) (string, error) {
+				if k == "bad" {
+					return "", errors.New("backend down")
+				}
+				return k, nil
+			},
+		})
+		t.Cleanup(func() { _ = c.Close() })
+
+		ctx := context.Background()
+		if _, err := c.Refresh(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Refresh(ctx, "bad"); err == nil {
+			t.Fatal("want the refresh of bad to fail")
+		}
+		if st := c.Stats(); st.Refreshes != 2 || st.RefreshErrors != 1 {
+			t.Fatalf("want 2 refreshes, 1 failed, got %+v", st.ShardStats)
+		}
+	}
+	if rec.ok.Load() != 1 || rec.failed.Load() != 1 {
+		t.Fatalf("want Refresh(true) and Refresh(false) once each, got ok=%d failed=%d", rec.ok.Load(), rec.failed.Load())
+	}
+}
+
+func TestCache_RefreshAfter(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:     8,
+		Shards:       1,
+		DefaultTTL:   time.Hour,
+		RefreshAfter: time.Minute,
+		Clock:        clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			n := atomic.AddInt64(&calls, 1)
+			return fmt.Sprintf("%s:%d", k, n), nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	if v, err := c.GetOrLoad(ctx, "k"); err != nil || v != "k:1" {
+		t.Fatalf("want k:1, got %q err=%v", v, err)
+	}
+	misses := c.Stats().Misses
+	clk.add(2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		if v, err := c.GetOrLoad(ctx, "k"); err != nil || (v != "k:1" && v != "k:2") {
+			t.Fatalf("unexpected %q err=%v", v, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, ok := c.Get("k"); ok && v == "k:2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not replace the entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	st := c.Stats()
+	if atomic.LoadInt64(&calls) != 2 || st.Refreshes != 1 || st.Misses != misses {
+		t.Fatalf("want one refresh and no new misses, got calls=%d %+v", atomic.LoadInt64(&calls), st.ShardStats)
+	}
+}
+
+// Refresh forces a reload and keeps the old value when it fails.
+func TestCache_Refresh(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("backend down")
+	var fail atomic.Bool
+	var calls int64
+	c := New[string, string](Options[string, string]{
+		Capacity: 8,
+		Loader: func(_ context.Context, k string) (string, error) {
+			if fail.Load() {
+				return "", boom
+			}
+			return fmt.Sprintf("%s:%d", k, atomic.AddInt64(&calls, 1)), nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	c.Set("k", "v0")
+	if v, err := c.Refresh(ctx, "k"); err != nil || v != "k:1" {
+		t.Fatalf("want k:1, got %q err=%v", v, err)
+	}
+	if v, _ := c.Get("k"); v != "k:1" {
+		t.Fatalf("Refresh must store the new value, got %q", v)
+	}
+
+	fail.Store(true)
+	if _, err := c.Refresh(ctx, "k"); !errors.Is(err, boom) {
+		t.Fatalf("want %v, got %v", boom, err)
+	}
+	if v, _ := c.Get("k"); v != "k:1" {
+		t.Fatalf("failed Refresh must keep the old value, got %q", v)
+	}
+	if st := c.Stats(); st.Refreshes != 2 || st.RefreshErrors != 1 {
+		t.Fatalf("want 2 refreshes / 1 error, got %d / %d", st.Refreshes, st.RefreshErrors)
+	}
+}
+
+// XFetch starts one early background reload of a loaded entry before its
+// deadline; without it the entry is only reloaded after expiry.
+func TestCache_XFetch(t *testing.T) {
+	t.Parallel()
+
+	run := func(beta float64) (refreshes uint64) {
+		var calls int64
+		clk := &fakeClock{}
+		c := New[string, int](Options[string, int]{
+			Capacity:   8,
+			Shards:     1,
+			DefaultTTL: time.Second,
+			XFetchBeta: beta,
+			Clock:      clk,
+			Loader: func(_ context.Context, _ string) (int, error) {
+				time.Sleep(20 * time.Millisecond) // recorded load time
+				return int(atomic.AddInt64(&calls, 1)), nil
+			},
+		})
+		defer func() { _ = c.Close() }()
+
+		if _, err := c.GetOrLoad(context.Background(), "k"); err != nil {
+			t.Fatal(err)
+		}
+		clk.add(999 * time.Millisecond) // 1ms before the deadline
+		// All lookups happen while the early refresh is still in flight.
+		for i := 0; i < 5; i++ {
+			if _, err := c.GetOrLoad(context.Background(), "k"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for beta > 0 && c.Stats().Refreshes == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(30 * time.Millisecond) // let a (wrong) second reload show up
+		return c.Stats().Refreshes
+	}
+
+	// β=1000: load time × 1000 ≫ 1ms left, so the very first lookup fires.
+	if got := run(1000); got != 1 {
+		t.Fatalf("want exactly one early refresh, got %d", got)
+	}
+	if got := run(0); got != 0 {
+		t.Fatalf("XFetch disabled: want no refresh, got %d", got)
+	}
+}
+
+// TTLJitter spreads deadlines of entries written together within
+// (ttl·(1-jitter), ttl].
+func TestCache_TTLJitter(t *testing.T) {
+	t.Parallel()
+
+	clk := &fakeClock{}
+	c := New[int, int](Options[int, int]{
+		Capacity:  256,
+		TTLJitter: 0.5,
+		Clock:     clk,
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	const n = 100
+	for i := 0; i < n; i++ {
+		c.SetWithTTL(i, i, time.Second)
+	}
+	alive := func() int {
+		cnt := 0
+		for i := 0; i < n; i++ {
+			if _, ok := c.Get(i); ok {
+				cnt++
+			}
+		}
+		return cnt
+	}
+
+	clk.add(500 * time.Millisecond)
+	if got := alive(); got != n {
+		t.Fatalf("no entry may expire before ttl·(1-jitter), alive=%d", got)
+	}
+	clk.add(250 * time.Millisecond)
+	if got := alive(); got == 0 || got == n {
+		t.Fatalf("deadlines must be spread, alive=%d at 750ms", got)
+	}
+	clk.add(251 * time.Millisecond)
+	if got := alive(); got != 0 {
+		t.Fatalf("no entry may outlive its ttl, alive=%d", got)
+	}
+}
+
+// Sliding TTL: hits extend the deadline, both cache-wide (ExpireAfterAccess)
+// and per entry (SetWithIdleTTL); the janitor honors extended deadlines.
+func TestCache_ExpireAfterAccess(t *testing.T) {
+	t.Parallel()
+
+	for _, readBuf := range []bool{true, false} {
+		clk := &fakeClock{}
+		c := New[string, int](Options[string, int]{
+			Capacity:          8,
+			Shards:            1,
+			ExpireAfterAccess: time.Second,
+			DisableReadBuffer: !readBuf,
+			Clock:             clk,
+		}).(*cache[string, int])
+
+		c.Set("hot", 1)
+		c.Set("cold", 2)
+		c.SetWithIdleTTL("session", 3, 3*time.Second)
+		c.SetWithTTL("fixed", 4, 2500*time.Millisecond)
+
+		for i := 0; i < 4; i++ { // 2s total, hot read every 500ms
+			clk.add(500 * time.Millisecond)
+			if _, ok := c.Get("hot"); !ok {
+				t.Fatalf("readbuf=%v: hot entry expired despite reads", readBuf)
+			}
+		}
+		if _, ok := c.Get("cold"); ok {
+			t.Fatalf("readbuf=%v: cold entry must expire after 1s idle", readBuf)
+		}
+		if _, ok := c.Get("session"); !ok { // idle 2s < 3s; extends to 5s
+			t.Fatalf("readbuf=%v: session must be alive", readBuf)
+		}
+
+		clk.add(900 * time.Millisecond) // t=2.9s
+		if n := c.shards[0].expire(expireBatch); n != 1 {
+			t.Fatalf("readbuf=%v: janitor must reclaim only the fixed entry, got %d", readBuf, n)
+		}
+		if c.Len() != 2 {
+			t.Fatalf("readbuf=%v: want hot+session, Len=%d", readBuf, c.Len())
+		}
+		clk.add(time.Second) // hot idle 1.9s
+		if n := c.shards[0].expire(expireBatch); n != 1 {
+			t.Fatalf("readbuf=%v: janitor must reclaim hot after idling, got %d", readBuf, n)
+		}
+		if _, ok := c.Get("session"); !ok {
+			t.Fatalf("readbuf=%v: session was read 1.9s ago, idle 3s", readBuf)
+		}
+		_ = c.Close()
+	}
+}
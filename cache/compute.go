@@ -0,0 +1,174 @@
+package shardcache
+
+// Op tells Compute what to do with the value returned by its callback.
+type Op int
+
+const (
+	// OpKeep leaves the entry as it was (or absent).
+	OpKeep Op = iota
+	// OpSet stores the returned value like Set (DefaultTTL, Cost).
+	OpSet
+	// OpRemove deletes the entry like Remove.
+	OpRemove
+)
+
+// GetOrSet returns the value cached for k if present (loaded = true);
+// otherwise it stores v like Set and returns it. The check and the insert
+// are atomic.
+func (c *cache[K, V]) GetOrSet(k K, v V) (actual V, loaded bool) {
+	actual, _ = c.Compute(k, func(old V, ok bool) (V, Op) {
+		if ok {
+			loaded = true
+			return old, OpKeep
+		}
+		return v, OpSet
+	})
+	return actual, loaded
+}
+
+// Compute calls fn with the current value of k (ok = false if absent or
+// expired) under the shard lock and applies the returned Op atomically.
+// It returns the resulting value and whether k is present afterwards.
+//
+// fn must not call back into the cache: the shard lock is held.
+func (c *cache[K, V]) Compute(k K, fn func(old V, ok bool) (V, Op)) (V, bool) {
+	if c.closed.Load() {
+		var zero V
+		return zero, false
+	}
+	v, _, ok := c.compute(k, func(old V, _ uint64, ok bool) (V, Op) { return fn(old, ok) })
+	return v, ok
+}
+
+// GetWithVersion is Get that also returns the version of the entry. Every
+// write of k (Set, Compute, loads, ...) assigns it a new, larger version.
+func (c *cache[K, V]) GetWithVersion(k K) (V, uint64, bool) {
+	if c.closed.Load() {
+		var zero V
+		return zero, 0, false
+	}
+	return c.getShard(k).GetWithVersion(k)
+}
+
+// SetIfVersion stores v like Set only if the entry of k still has the given
+// version; version 0 means k must be absent. It returns the new version and
+// whether v was written.
+func (c *cache[K, V]) SetIfVersion(k K, v V, version uint64) (uint64, bool) {
+	written := false
+	_, ver, ok := c.compute(k, func(old V, cur uint64, _ bool) (V, Op) {
+		if cur != version {
+			return old, OpKeep
+		}
+		written = true
+		return v, OpSet
+	})
+	if !written || !ok {
+		return 0, false
+	}
+	return ver, true
+}
+
+// RemoveIfVersion removes k only if its entry has the given version.
+func (c *cache[K, V]) RemoveIfVersion(k K, version uint64) bool {
+	removed := false
+	c.compute(k, func(old V, cur uint64, ok bool) (V, Op) {
+		if ok && cur == version {
+			removed = true
+			return old, OpRemove
+		}
+		return old, OpKeep
+	})
+	return removed
+}
+
+// CompareAndSwap replaces the value of k with v only if it is present and
+// equal to old (per Options.Equal, else ==). Reports whether it swapped.
+func (c *cache[K, V]) CompareAndSwap(k K, old, v V) bool {
+	swapped := false
+	c.Compute(k, func(cur V, ok bool) (V, Op) {
+		if ok && c.equal(cur, old) {
+			swapped = true
+			return v, OpSet
+		}
+		return cur, OpKeep
+	})
+	return swapped
+}
+
+// compute runs fn (which also receives the current version, 0 if absent)
+// through shard.compute with the expiry of a Set.
+func (c *cache[K, V]) compute(k K, fn func(old V, version uint64, ok bool) (V, Op)) (V, uint64, bool) {
+	if c.closed.Load() {
+		var zero V
+		return zero, 0, false
+	}
+	ttl, idle := c.defaultExpiry()
+	v, ver, ok := c.getShard(k).compute(k, fn, ttl, idle, c.costOf)
+	c.enforceBudget()
+	return v, ver, ok
+}
+
+// equal compares values with Options.Equal, falling back to == on the
+// dynamic values (which panics for non-comparable types such as slices).
+func (c *cache[K, V]) equal(a, b V) bool {
+	if c.opt.Equal != nil {
+		return c.opt.Equal(a, b)
+	}
+	return any(a) == any(b)
+}
+
+// compute runs fn on the live entry for k under the write lock and applies
+// its Op, returning the resulting value and version. Stored values get the
+// deadline ttl/idle and the cost from costOf. A kept entry counts as
+// accessed (policy promotion, sliding TTL).
+func (s *shard[K, V]) compute(k K, fn func(old V, version uint64, ok bool) (V, Op), ttl, idle int64, costOf func(V) int32) (V, uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	n, ok := s.m[k]
+	live := ok && !s.expiredLocked(n)
+	var old V
+	var ver uint64
+	if live {
+		old, ver = n.val, n.version
+	}
+
+	v, op := fn(old, ver, live)
+	switch op {
+	case OpSet:
+		s.dropNegativeLocked(k)
+		switch {
+		case live:
+			s.updateLocked(n, v, ttl, idle, costOf(v), 0)
+		case ok:
+			// An expired entry (retained for SWR) is replaced, not updated.
+			s.evictNode(n, EvictTTL)
+			fallthrough
+		default:
+			n = s.insertLocked(k, v, ttl, idle, costOf(v), 0)
+		}
+		s.enforceLimitsLocked()
+		if s.m[k] != n { // the policy may have rejected it
+			var zero V
+			return zero, 0, false
+		}
+		return v, n.version, true
+	case OpRemove:
+		s.dropNegativeLocked(k)
+		if ok {
+			s.deleteLocked(n)
+			s.removes.Add(1)
+		}
+		var zero V
+		return zero, 0, false
+	default:
+		if !live {
+			var zero V
+			return zero, 0, false
+		}
+		s.touch(n)
+		s.pol.OnGet(n)
+		return n.val, n.version, true
+	}
+}
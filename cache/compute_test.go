@@ -0,0 +1,199 @@
+package shardcache
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Concurrent GetOrSet calls for one key agree on a single stored value.
+func TestCache_GetOrSet(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{Capacity: 8})
+	t.Cleanup(func() { _ = c.Close() })
+
+	var stored int64
+	var g errgroup.Group
+	results := make([]int, 32)
+	for i := range results {
+		g.Go(func() error {
+			v, loaded := c.GetOrSet("k", i)
+			if !loaded {
+				atomic.AddInt64(&stored, 1)
+			}
+			results[i] = v
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if stored != 1 {
+		t.Fatalf("exactly one GetOrSet must store, got %d", stored)
+	}
+	want, _ := c.Get("k")
+	for i, v := range results {
+		if v != want {
+			t.Fatalf("caller %d saw %d, cached %d", i, v, want)
+		}
+	}
+}
+
+// Compute applies read-modify-write updates atomically and honors Op.
+func TestCache_Compute(t *testing.T) {
+	t.Parallel()
+
+	clk := &fakeClock{}
+	c := New[string, int](Options[string, int]{
+		Capacity:   8,
+		DefaultTTL: time.Second,
+		Cost:       func(v int) int { return v },
+		Clock:      clk,
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	incr := func(old int, _ bool) (int, Op) { return old + 1, OpSet }
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Compute("n", incr)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, ok := c.Get("n"); !ok || v != 800 {
+		t.Fatalf("want 800, got %d ok=%v", v, ok)
+	}
+	if cost := c.Stats().Cost; cost != 800 {
+		t.Fatalf("Compute must account the new cost, got %d", cost)
+	}
+
+	// OpKeep on an absent key leaves it absent.
+	if _, ok := c.Compute("none", func(int, bool) (int, Op) { return 1, OpKeep }); ok {
+		t.Fatal("OpKeep must not insert")
+	}
+	if _, ok := c.Get("none"); ok {
+		t.Fatal("OpKeep must not insert")
+	}
+
+	// Stored values get DefaultTTL; an expired entry is reported as absent.
+	clk.add(2 * time.Second)
+	c.Compute("n", func(old int, ok bool) (int, Op) {
+		if ok {
+			t.Errorf("expired entry must be absent, got %d", old)
+		}
+		return 1, OpSet
+	})
+	if v, ok := c.Get("n"); !ok || v != 1 {
+		t.Fatalf("want 1, got %d ok=%v", v, ok)
+	}
+
+	if _, ok := c.Compute("n", func(int, bool) (int, Op) { return 0, OpRemove }); ok {
+		t.Fatal("OpRemove must report absence")
+	}
+	if _, ok := c.Get("n"); ok {
+		t.Fatal("OpRemove must delete the entry")
+	}
+}
+
+// CompareAndSwap uses == by default and Options.Equal for other values.
+func TestCache_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{Capacity: 8})
+	t.Cleanup(func() { _ = c.Close() })
+
+	if c.CompareAndSwap("k", 0, 1) {
+		t.Fatal("CAS on an absent key must fail")
+	}
+	c.Set("k", 1)
+	if c.CompareAndSwap("k", 2, 3) {
+		t.Fatal("CAS with a wrong old value must fail")
+	}
+	if !c.CompareAndSwap("k", 1, 2) {
+		t.Fatal("CAS with the current value must succeed")
+	}
+	if v, _ := c.Get("k"); v != 2 {
+		t.Fatalf("want 2, got %d", v)
+	}
+
+	b := New[string, []byte](Options[string, []byte]{Capacity: 8, Equal: bytes.Equal})
+	t.Cleanup(func() { _ = b.Close() })
+	b.Set("k", []byte("a"))
+	if !b.CompareAndSwap("k", []byte("a"), []byte("b")) {
+		t.Fatal("CAS must compare slices with Options.Equal")
+	}
+	if v, _ := b.Get("k"); string(v) != "b" {
+		t.Fatalf("want b, got %q", v)
+	}
+}
+
+// Versions grow on every write and guard SetIfVersion/RemoveIfVersion.
+func TestCache_Versions(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{Capacity: 8})
+	t.Cleanup(func() { _ = c.Close() })
+
+	if _, _, ok := c.GetWithVersion("k"); ok {
+		t.Fatal("want miss")
+	}
+	v1, ok := c.SetIfVersion("k", 1, 0) // 0 = insert if absent
+	if !ok || v1 == 0 {
+		t.Fatalf("insert with version 0 must succeed, got %d %v", v1, ok)
+	}
+	if _, ok := c.SetIfVersion("k", 9, 0); ok {
+		t.Fatal("version 0 must fail for a present key")
+	}
+
+	c.Set("k", 2) // a concurrent writer
+	val, v2, ok := c.GetWithVersion("k")
+	if !ok || val != 2 || v2 <= v1 {
+		t.Fatalf("want 2 with version > %d, got %d@%d", v1, val, v2)
+	}
+	if _, ok := c.SetIfVersion("k", 3, v1); ok {
+		t.Fatal("stale version must not overwrite")
+	}
+	v3, ok := c.SetIfVersion("k", 3, v2)
+	if !ok || v3 <= v2 {
+		t.Fatalf("current version must write, got %d %v", v3, ok)
+	}
+
+	if c.RemoveIfVersion("k", v2) {
+		t.Fatal("stale version must not remove")
+	}
+	if !c.RemoveIfVersion("k", v3) {
+		t.Fatal("current version must remove")
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("entry must be gone")
+	}
+
+	// Optimistic read-modify-write loop under contention.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for {
+					n, ver, _ := c.GetWithVersion("n")
+					if _, ok := c.SetIfVersion("n", n+1, ver); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := c.Get("n"); n != 400 {
+		t.Fatalf("want 400, got %d", n)
+	}
+}
@@ -7,30 +7,85 @@
 //   - Concurrency: the cache is split into shards, each protected by an
 //     RWMutex. The default shard count is chosen by a heuristic
 //     (ReasonableShardCount) and is a power of two. Picking shards reduces
-//     contention while keeping memory overhead small.
+//     contention while keeping memory overhead small. Get serves hits under
+//     the read lock and records them in a lossy striped read buffer; the
+//     implied policy promotions are applied in batches under the write lock.
 //
 //   - Storage: each shard keeps a map[K]*node for lookups and an intrusive
 //     MRU↔LRU doubly linked list for ordering. All operations are O(1) expected.
 //
 //   - Policies: eviction policy is pluggable via the policy package.
-//     LRU is the default. A 2Q policy is provided (resists scan pollution).
-//     More policies (e.g. WTinyLFU) can be added without changing the shard.
+//     LRU is the default. A 2Q policy is provided (resists scan pollution),
+//     as is W-TinyLFU (policy/tinylfu: frequency-based admission for Zipf and
+//     scan-heavy workloads). More policies can be added without changing the shard.
+//     Count and cost limits evict the node returned by ShardPolicy.Victim.
 //
 //   - TTL: entries can have per-item deadlines (UnixNano). Expiration is lazy
 //     on read (and also enforced while the shard trims to capacity).
+//     Options.ExpireAfterAccess and SetWithIdleTTL give sliding deadlines
+//     that every hit extends.
+//     With Options.SWR, GetOrLoad serves a recently expired entry and reloads
+//     it once in the background (serve-stale-while-revalidate). With
+//     Options.StaleIfError it falls back to the expired value, flagged with
+//     ErrStale, when the synchronous reload fails.
+//     Options.RefreshAfter reloads entries past that age in the background
+//     while serving them; Refresh forces a reload. Options.XFetchBeta does
+//     the same probabilistically as the deadline nears (XFetch), and
+//     Options.TTLJitter spreads deadlines written at the same time.
+//     Options.ExpireInterval adds a background janitor that reclaims expired
+//     entries using a per-shard expiry heap; it is stopped by Close.
+//
+//   - Iteration: All, Keys and ByRecency return Go iterators. Shards are
+//     copied one at a time under their read lock and yielded unlocked, so
+//     each shard is a consistent snapshot but the cache as a whole is not.
+//
+//   - Inspection: Peek, Contains and Inspect read an entry without promoting
+//     it or counting a hit/miss; Inspect reports remaining TTL, cost, version,
+//     age and the policy segment (policy.Segmenter).
+//
+//   - Atomic updates: GetOrSet, Compute and CompareAndSwap read and write a
+//     key under one shard lock; Options.Equal compares values for
+//     CompareAndSwap when == cannot. Every write gives the entry a new
+//     version; GetWithVersion with SetIfVersion/RemoveIfVersion implement
+//     optimistic concurrency.
 //
 //   - Cost/MaxCost: besides entry count (Capacity), you may account a user-defined
-//     "cost" per value (Options.Cost) and enforce a global MaxCost. Shards split
-//     the MaxCost budget evenly.
+//     "cost" per value (Options.Cost) and enforce a global MaxCost. The budget
+//     is shared by all shards: when it is exceeded, victims are evicted from
+//     the heaviest shard. Options.GlobalCapacity does the same for Capacity.
 //
-//   - GetOrLoad: coalesces concurrent loads for the same key using singleflight.
-//     If Loader is nil, GetOrLoad returns ErrNoLoader.
+//   - Snapshot/Restore: persist live entries (remaining TTL, cost, per-shard
+//     recency order) through pluggable codecs; the stream is versioned and
+//     checksummed.
 //
-//   - Metrics: Options.Metrics receives Hit/Miss/Evict/Size signals.
-//     By default NoopMetrics is used; plug a Prometheus adapter to export metrics.
+//   - GetOrLoad: coalesces concurrent loads for the same key using singleflight.
+//     The load runs under a context detached from the first caller and is
+//     cancelled only when all waiters have given up; loader panics become
+//     errors (ErrLoaderPanic) for every waiter.
+//     GetOrLoadAsync returns a channel instead of blocking.
+//     If Loader is nil, GetOrLoad returns ErrNoLoader. Options.LoaderWithInfo
+//     also returns a LoadInfo (TTL, Cost, NoCache) for the loaded entry.
+//     GetOrLoadMany resolves a batch of misses with a single
+//     Options.BulkLoader call. Load failures can be cached per key:
+//     ErrNotFound for Options.NegativeTTL, other errors for Options.ErrorTTL
+//     with exponential backoff. Options.MaxConcurrentLoads bounds concurrent
+//     loader calls and Options.BreakerThreshold opens a circuit breaker
+//     (ErrCircuitOpen) after consecutive failures.
+//
+//   - Metrics: Options.Metrics receives Hit/Miss/Evict/Size signals, and
+//     refreshes and breaker state changes if it also implements
+//     RefreshMetrics or CircuitMetrics. By default NoopMetrics is used; plug
+//     a Prometheus adapter to export metrics.
+//     Independently of Metrics, Stats returns cumulative per-shard counters
+//     (hits, misses, evictions by reason, removes, loads and load latency).
 //
 //   - Callbacks: Options.OnEvict(k, v, reason) is called for every eviction
-//     (reason is one of EvictPolicy, EvictTTL, EvictCapacity).
+//     (reason is one of EvictPolicy, EvictTTL, EvictCapacity, EvictClosed).
+//
+//   - Shutdown: Shutdown stops background workers and waits for loads in
+//     flight until its ctx is done; Close cancels them instead. Afterwards
+//     error-returning methods return ErrClosed. Options.EvictOnClose evicts
+//     every entry on close.
 //
 // Basic usage
 //
@@ -0,0 +1,122 @@
+package shardcache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap is a per-shard min-heap of nodes ordered by their absolute
+// deadline (node.exp). Only nodes with a TTL are tracked; node.hidx holds the
+// position of a node inside the heap (-1 when not tracked).
+//
+// All methods must be called with the shard lock held.
+type expiryHeap[K comparable, V any] []*node[K, V]
+
+func (h expiryHeap[K, V]) Len() int           { return len(h) }
+func (h expiryHeap[K, V]) Less(i, j int) bool { return h[i].exp < h[j].exp }
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].hidx = i
+	h[j].hidx = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	n := x.(*node[K, V])
+	n.hidx = len(*h)
+	*h = append(*h, n)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	last := len(old) - 1
+	n := old[last]
+	old[last] = nil // avoid retaining the node
+	n.hidx = -1
+	*h = old[:last]
+	return n
+}
+
+// track inserts, repositions or drops n according to its current deadline.
+func (h *expiryHeap[K, V]) track(n *node[K, V]) {
+	switch {
+	case n.exp == 0 && n.hidx >= 0:
+		heap.Remove(h, n.hidx)
+	case n.exp == 0:
+		// no TTL, nothing to track
+	case n.hidx >= 0:
+		heap.Fix(h, n.hidx)
+	default:
+		heap.Push(h, n)
+	}
+}
+
+// untrack removes n from the heap if present.
+func (h *expiryHeap[K, V]) untrack(n *node[K, V]) {
+	if n.hidx >= 0 {
+		heap.Remove(h, n.hidx)
+	}
+}
+
+// peek returns the node with the earliest deadline (or nil).
+func (h expiryHeap[K, V]) peek() *node[K, V] {
+	if len(h) == 0 {
+		return nil
+	}
+	return h[0]
+}
+
+// expireBatch bounds how many entries a single lock acquisition may reclaim,
+// so a large expiry wave does not stall readers of the shard.
+const expireBatch = 256
+
+// janitor periodically reclaims expired entries from every shard until stop
+// is closed. It runs in its own goroutine, started by New when
+// Options.ExpireInterval > 0.
+func (c *cache[K, V]) janitor(every time.Duration) {
+	defer c.wg.Done()
+
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			for _, s := range c.shards {
+				for s.expire(expireBatch) == expireBatch {
+					// keep draining in bounded batches
+				}
+			}
+		}
+	}
+}
+
+// expire evicts up to limit expired entries (EvictTTL) and returns how many
+// were removed. Entries inside the SWR window are kept until it elapses.
+func (s *shard[K, V]) expire(limit int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	grace := s.graceLocked()
+	removed := 0
+	for removed < limit {
+		n := s.expq.peek()
+		if n == nil || now <= n.exp+grace {
+			break
+		}
+		if d := n.deadline(); d != n.exp {
+			// Sliding TTL extended by hits since the heap was ordered.
+			n.exp = d
+			s.expq.track(n)
+			continue
+		}
+		s.evictNode(n, EvictTTL)
+		removed++
+	}
+	s.purgeNegativeLocked(now)
+	if removed > 0 {
+		s.opt.Metrics.Size(s.len, s.cost)
+	}
+	return removed
+}
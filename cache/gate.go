@@ -0,0 +1,162 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by loading APIs while the loader circuit
+// breaker is open (see Options.BreakerThreshold).
+var ErrCircuitOpen = errorsNew("cache: loader circuit open")
+
+// CircuitState is the state of the loader circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed — loads run normally.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen — loads fail fast with ErrCircuitOpen until the cooldown ends.
+	CircuitOpen
+	// CircuitHalfOpen — a single trial load decides whether to close again.
+	CircuitHalfOpen
+)
+
+// defaultBreakerCooldown is used when BreakerThreshold is set without a
+// BreakerCooldown.
+const defaultBreakerCooldown = 5 * time.Second
+
+// loadGate guards loader calls with an optional concurrency limit and an
+// optional circuit breaker. A nil *loadGate lets every call through.
+type loadGate struct {
+	sem chan struct{} // nil = unlimited concurrency
+
+	threshold int            // consecutive failures that open the circuit (0 = no breaker)
+	cooldown  int64          // ns the circuit stays open before a trial load
+	metrics   CircuitMetrics // Options.Metrics if it implements it, else NoopMetrics
+	now       func() int64
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int   // consecutive failures while closed
+	openedAt int64 // UnixNano the circuit was last opened
+	probing  bool  // a half-open trial load is in flight
+}
+
+// newLoadGate returns the gate configured by opt, or nil if neither a
+// concurrency limit nor a breaker is set.
+func newLoadGate[K comparable, V any](opt Options[K, V], now func() int64) *loadGate {
+	if opt.MaxConcurrentLoads <= 0 && opt.BreakerThreshold <= 0 {
+		return nil
+	}
+	g := &loadGate{metrics: NoopMetrics{}, now: now}
+	if m, ok := opt.Metrics.(CircuitMetrics); ok {
+		g.metrics = m
+	}
+	if opt.MaxConcurrentLoads > 0 {
+		g.sem = make(chan struct{}, opt.MaxConcurrentLoads)
+	}
+	if opt.BreakerThreshold > 0 {
+		g.threshold = opt.BreakerThreshold
+		g.cooldown = int64(opt.BreakerCooldown)
+		if g.cooldown <= 0 {
+			g.cooldown = int64(defaultBreakerCooldown)
+		}
+	}
+	return g
+}
+
+// acquire admits one loader call: it fails fast with ErrCircuitOpen, or
+// waits for a concurrency slot until ctx is done. On success the caller must
+// call release with the loader's error exactly once.
+func (g *loadGate) acquire(ctx context.Context) (release func(ctx context.Context, err error), err error) {
+	if g == nil {
+		return func(context.Context, error) {}, nil
+	}
+	probe, err := g.allow()
+	if err != nil {
+		return nil, err
+	}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-ctx.Done():
+			g.record(ctx, probe, ctx.Err())
+			return nil, ctx.Err()
+		}
+	}
+	return func(ctx context.Context, err error) {
+		if g.sem != nil {
+			<-g.sem
+		}
+		g.record(ctx, probe, err)
+	}, nil
+}
+
+// allow checks the breaker. probe reports that the caller is the single
+// trial load of a half-open circuit.
+func (g *loadGate) allow() (probe bool, err error) {
+	if g.threshold == 0 {
+		return false, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch g.state {
+	case CircuitOpen:
+		if g.now()-g.openedAt < g.cooldown {
+			return false, ErrCircuitOpen
+		}
+		g.setStateLocked(CircuitHalfOpen)
+	case CircuitClosed:
+		return false, nil
+	}
+	if g.probing {
+		return false, ErrCircuitOpen
+	}
+	g.probing = true
+	return true, nil
+}
+
+// record feeds the outcome of a load into the breaker. ErrNotFound counts as
+// success (the backend answered); a failure caused by ctx being done is
+// neutral.
+func (g *loadGate) record(ctx context.Context, probe bool, err error) {
+	if g.threshold == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if probe {
+		g.probing = false
+	}
+	switch {
+	case err == nil || errors.Is(err, ErrNotFound):
+		g.failures = 0
+		if probe {
+			g.setStateLocked(CircuitClosed)
+		}
+	case ctx.Err() != nil:
+		// The waiters gave up; says nothing about the backend.
+	case probe:
+		g.openLocked()
+	case g.state == CircuitClosed:
+		g.failures++
+		if g.failures >= g.threshold {
+			g.openLocked()
+		}
+	}
+}
+
+func (g *loadGate) openLocked() {
+	g.failures = 0
+	g.openedAt = g.now()
+	g.setStateLocked(CircuitOpen)
+}
+
+func (g *loadGate) setStateLocked(st CircuitState) {
+	if g.state != st {
+		g.state = st
+		g.metrics.Circuit(st)
+	}
+}
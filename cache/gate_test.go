@@ -0,0 +1,144 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// At most MaxConcurrentLoads loader calls run at once; a queued load gives up
+// when its ctx is done.
+func TestCache_MaxConcurrentLoads(t *testing.T) {
+	t.Parallel()
+
+	var running, peak int64
+	release := make(chan struct{})
+	c := New[int, int](Options[int, int]{
+		Capacity:           64,
+		MaxConcurrentLoads: 2,
+		Loader: func(_ context.Context, k int) (int, error) {
+			n := atomic.AddInt64(&running, 1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt64(&running, -1)
+			return k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	var g errgroup.Group
+	for i := 0; i < 6; i++ {
+		g.Go(func() error {
+			if v, err := c.GetOrLoad(context.Background(), i); err != nil || v != i {
+				return fmt.Errorf("key %d: got %d err=%v", i, v, err)
+			}
+			return nil
+		})
+	}
+	for atomic.LoadInt64(&running) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetOrLoad(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("queued load must honor ctx, got %v", err)
+	}
+
+	close(release)
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if p := atomic.LoadInt64(&peak); p != 2 {
+		t.Fatalf("want peak concurrency 2, got %d", p)
+	}
+}
+
+type circuitRecorder struct {
+	NoopMetrics
+	mu     sync.Mutex
+	states []CircuitState
+}
+
+func (r *circuitRecorder) Circuit(st CircuitState) {
+	r.mu.Lock()
+	r.states = append(r.states, st)
+	r.mu.Unlock()
+}
+
+// The breaker opens after BreakerThreshold consecutive failures, fails fast
+// during the cooldown, and a successful trial load closes it again.
+func TestCache_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("backend down")
+	var calls int64
+	var fail atomic.Bool
+	fail.Store(true)
+	clk := &fakeClock{}
+	m := &circuitRecorder{}
+	c := New[int, int](Options[int, int]{
+		Capacity:         64,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Second,
+		Metrics:          m,
+		Clock:            clk,
+		Loader: func(_ context.Context, k int) (int, error) {
+			atomic.AddInt64(&calls, 1)
+			if fail.Load() {
+				return 0, boom
+			}
+			return k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad(ctx, i); !errors.Is(err, boom) {
+			t.Fatalf("want %v, got %v", boom, err)
+		}
+	}
+	if _, err := c.GetOrLoad(ctx, 10); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Fatalf("open circuit must not call the loader, calls=%d", n)
+	}
+
+	// Cooldown over: a failing trial re-opens the circuit.
+	clk.add(time.Second)
+	if _, err := c.GetOrLoad(ctx, 10); !errors.Is(err, boom) {
+		t.Fatalf("trial load: want %v, got %v", boom, err)
+	}
+	if _, err := c.GetOrLoad(ctx, 11); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want ErrCircuitOpen after failed trial, got %v", err)
+	}
+
+	// Next trial succeeds and closes it.
+	clk.add(time.Second)
+	fail.Store(false)
+	for i := 20; i < 23; i++ {
+		if v, err := c.GetOrLoad(ctx, i); err != nil || v != i {
+			t.Fatalf("want %d, got %d err=%v", i, v, err)
+		}
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if fmt.Sprint(m.states) != fmt.Sprint(want) {
+		t.Fatalf("state changes: want %v, got %v", want, m.states)
+	}
+}
@@ -0,0 +1,78 @@
+package shardcache
+
+import (
+	"time"
+
+	"github.com/IvanBrykalov/shardcache/policy"
+)
+
+// EntryInfo describes a resident entry (see Inspect).
+type EntryInfo struct {
+	// TTL is the time left until the entry expires; 0 = no expiration.
+	TTL time.Duration
+	// Idle is the sliding TTL (ExpireAfterAccess, SetWithIdleTTL); 0 if the
+	// deadline is fixed.
+	Idle time.Duration
+	// Cost is the logical cost accounted for the entry.
+	Cost int
+	// Version is the entry version (see GetWithVersion).
+	Version uint64
+	// Age is the time since the entry was last written.
+	Age time.Duration
+	// Segment is the policy segment holding the entry (e.g. "A1in"/"Am" for
+	// 2Q); empty if the policy does not implement policy.Segmenter.
+	Segment string
+}
+
+// Peek returns the value for k without promoting it, extending a sliding
+// TTL or counting a hit or miss. Expired entries are reported as absent.
+func (c *cache[K, V]) Peek(k K) (V, bool) {
+	var v V
+	ok := c.peek(k, func(n *node[K, V], _ int64) { v = n.val })
+	return v, ok
+}
+
+// Contains reports whether k is present and not expired, with the same
+// side-effect freedom as Peek.
+func (c *cache[K, V]) Contains(k K) bool {
+	return c.peek(k, func(*node[K, V], int64) {})
+}
+
+// Inspect returns metadata of the entry for k without touching it like Peek.
+// Hits still waiting in the read buffer are not reflected in Segment yet.
+func (c *cache[K, V]) Inspect(k K) (EntryInfo, bool) {
+	var info EntryInfo
+	ok := c.peek(k, func(n *node[K, V], now int64) {
+		info = EntryInfo{
+			Idle:    time.Duration(n.idle),
+			Cost:    int(n.cost),
+			Version: n.version,
+			Age:     time.Duration(now - n.written),
+		}
+		if exp := n.deadline(); exp != 0 {
+			info.TTL = time.Duration(exp - now)
+		}
+		if sp, ok := c.getShard(k).pol.(policy.Segmenter[K, V]); ok {
+			info.Segment = sp.Segment(n)
+		}
+	})
+	return info, ok
+}
+
+// peek calls fn with the live node for k (and the current time) under the
+// shard read lock. Reports whether k was found.
+func (c *cache[K, V]) peek(k K, fn func(n *node[K, V], now int64)) bool {
+	if c.closed.Load() {
+		return false
+	}
+	s := c.getShard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n, ok := s.m[k]
+	if !ok || s.expiredLocked(n) {
+		return false
+	}
+	fn(n, s.now())
+	return true
+}
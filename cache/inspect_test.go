@@ -0,0 +1,81 @@
+package shardcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IvanBrykalov/shardcache/policy/twoq"
+)
+
+// Peek and Contains neither promote nor count hits/misses.
+func TestCache_PeekContains(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{Capacity: 2, Shards: 1})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek: want 1, got %d ok=%v", v, ok)
+	}
+	if !c.Contains("b") || c.Contains("x") {
+		t.Fatal("Contains reports wrong presence")
+	}
+	if _, ok := c.Peek("x"); ok {
+		t.Fatal("Peek of a missing key must miss")
+	}
+
+	c.Set("c", 3) // "a" is still LRU: Peek did not promote it
+	if c.Contains("a") {
+		t.Fatal("Peek must not promote the entry")
+	}
+	if st := c.Stats(); st.Hits != 0 || st.Misses != 0 {
+		t.Fatalf("Peek/Contains must not count hits/misses, got %d/%d", st.Hits, st.Misses)
+	}
+}
+
+// Inspect reports TTL, cost, version, age and the policy segment; Peek does
+// not extend a sliding TTL.
+func TestCache_Inspect(t *testing.T) {
+	t.Parallel()
+
+	clk := &fakeClock{t: 1}
+	c := New[string, int](Options[string, int]{
+		Capacity:          8,
+		Shards:            1,
+		Policy:            twoq.New[string, int](2, 4),
+		Cost:              func(v int) int { return v * 10 },
+		Clock:             clk,
+		DisableReadBuffer: true, // apply promotions synchronously
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.SetWithTTL("k", 3, 10*time.Second)
+	_, ver, _ := c.GetWithVersion("k") // promotes A1in -> Am
+	clk.add(4 * time.Second)
+
+	info, ok := c.Inspect("k")
+	if !ok {
+		t.Fatal("Inspect must find the entry")
+	}
+	want := EntryInfo{TTL: 6 * time.Second, Cost: 30, Version: ver, Age: 4 * time.Second, Segment: "Am"}
+	if info != want {
+		t.Fatalf("want %+v, got %+v", want, info)
+	}
+	c.Set("n", 1)
+	if info, _ := c.Inspect("n"); info.Segment != "A1in" {
+		t.Fatalf("new entry must be in A1in, got %q", info.Segment)
+	}
+
+	c.SetWithIdleTTL("s", 1, time.Second)
+	clk.add(600 * time.Millisecond)
+	c.Peek("s")
+	clk.add(600 * time.Millisecond)
+	if c.Contains("s") {
+		t.Fatal("Peek must not extend a sliding TTL")
+	}
+	if _, ok := c.Inspect("s"); ok {
+		t.Fatal("expired entries must not be inspected")
+	}
+}
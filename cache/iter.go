@@ -0,0 +1,110 @@
+package shardcache
+
+import "iter"
+
+// All returns an iterator over the live entries of the cache.
+//
+// Shards are visited one at a time: the entries of a shard are copied under
+// its read lock and yielded after the lock is released, so the loop body may
+// call back into the cache. Each shard's entries are a consistent snapshot
+// taken when the iteration reaches it; writes to shards not yet visited are
+// observed, writes to visited shards are not. A key is yielded at most once.
+// Iteration does not promote entries or count hits.
+func (c *cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, s := range c.shards {
+			if c.closed.Load() {
+				return
+			}
+			for _, e := range s.entries() {
+				if !yield(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the live entries, with the same
+// guarantees as All.
+func (c *cache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range c.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// ByRecency returns an iterator over up to n of the most recently used live
+// entries. Each shard contributes its n hottest entries from the head of its
+// list; they are interleaved by rank (every shard's MRU first, then every
+// shard's second entry, ...). Recency is exact within a shard but only
+// approximate across shards, which keep no common clock. The shards are
+// copied one at a time before the first entry is yielded.
+func (c *cache[K, V]) ByRecency(n int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if n <= 0 || c.closed.Load() {
+			return
+		}
+		heads := make([][]kv[K, V], len(c.shards))
+		for i, s := range c.shards {
+			heads[i] = s.hottest(n)
+		}
+		for rank, left := 0, n; left > 0; rank++ {
+			more := false
+			for _, h := range heads {
+				if rank >= len(h) {
+					continue
+				}
+				more = true
+				if !yield(h[rank].key, h[rank].val) {
+					return
+				}
+				if left--; left == 0 {
+					return
+				}
+			}
+			if !more {
+				return
+			}
+		}
+	}
+}
+
+// kv is a key/value pair copied out of a shard.
+type kv[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// entries copies the live entries of the shard in MRU→LRU order.
+func (s *shard[K, V]) entries() []kv[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]kv[K, V], 0, s.len)
+	for n := s.head; n != nil; n = n.next {
+		if !s.expiredLocked(n) {
+			out = append(out, kv[K, V]{n.key, n.val})
+		}
+	}
+	return out
+}
+
+// hottest copies up to limit live entries from the MRU end of the list,
+// applying buffered hits first so the order reflects recent reads.
+func (s *shard[K, V]) hottest(limit int) []kv[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	out := make([]kv[K, V], 0, min(limit, s.len))
+	for n := s.head; n != nil && len(out) < limit; n = n.next {
+		if !s.expiredLocked(n) {
+			out = append(out, kv[K, V]{n.key, n.val})
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package shardcache
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// All and Keys visit every live entry exactly once; breaking out stops early.
+func TestCache_All(t *testing.T) {
+	t.Parallel()
+
+	c := New[int, int](Options[int, int]{Capacity: 1024, Shards: 8})
+	t.Cleanup(func() { _ = c.Close() })
+
+	want := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		c.Set(i, i*i)
+		want[i] = i * i
+	}
+	if got := maps.Collect(c.All()); !maps.Equal(got, want) {
+		t.Fatalf("All: want %d entries, got %d", len(want), len(got))
+	}
+	keys := slices.Sorted(c.Keys())
+	if !slices.Equal(keys, slices.Sorted(maps.Keys(want))) {
+		t.Fatalf("Keys mismatch: %v", keys)
+	}
+
+	n := 0
+	for k := range c.All() {
+		c.Remove(k) // the loop body may call back into the cache
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 || c.Len() != 90 {
+		t.Fatalf("break must stop the iteration, n=%d Len=%d", n, c.Len())
+	}
+	if st := c.Stats(); st.Hits != 0 {
+		t.Fatalf("iteration must not count hits, got %d", st.Hits)
+	}
+}
+
+// ByRecency yields the hottest entries in MRU order (single shard: exact).
+func TestCache_ByRecency(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, int](Options[string, int]{Capacity: 8, Shards: 1})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, i)
+	}
+	c.Get("b") // buffered hit, applied before walking the list
+
+	var got []string
+	for k := range c.ByRecency(3) {
+		got = append(got, k)
+	}
+	if want := []string{"b", "d", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if n := len(maps.Collect(c.ByRecency(10))); n != 4 {
+		t.Fatalf("ByRecency(10) must yield all 4 entries, got %d", n)
+	}
+}
@@ -1,6 +1,7 @@
 package shardcache
 
-// NoopMetrics is a Metrics implementation that does nothing.
+// NoopMetrics is a Metrics (and RefreshMetrics, CircuitMetrics)
+// implementation that does nothing.
 type NoopMetrics struct{}
 
 // Hit records a cache hit. NoopMetrics ignores the call.
@@ -14,3 +15,9 @@ func (NoopMetrics) Evict(EvictReason) {}
 
 // Size reports current resident size and cost. NoopMetrics ignores the call.
 func (NoopMetrics) Size(_ int, _ int64) {}
+
+// Refresh records a reload of a cached entry. NoopMetrics ignores the call.
+func (NoopMetrics) Refresh(bool) {}
+
+// Circuit reports a breaker state change. NoopMetrics ignores the call.
+func (NoopMetrics) Circuit(CircuitState) {}
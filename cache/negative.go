@@ -0,0 +1,152 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+)
+
+// negEntry is a cached loader failure for one key. A shard keeps its
+// entries in a list ordered by the last failure, most recent first, so that
+// bounding and purging them never scans the whole set.
+type negEntry[K comparable] struct {
+	key      K
+	err      error
+	exp      int64 // UnixNano until which err is returned by GetOrLoad
+	forget   int64 // UnixNano after which the failure streak is dropped
+	failures int   // consecutive failures (drives exponential backoff)
+
+	prev, next *negEntry[K]
+}
+
+// defaultErrorBackoffFactor caps the error backoff at ErrorTTL × factor
+// when ErrorMaxTTL is not set.
+const defaultErrorBackoffFactor = 32
+
+// cacheLoadError records a failed load of k for negative caching.
+// ErrNotFound (and errors wrapping it) is cached for NegativeTTL; other
+// errors for ErrorTTL, doubled on each consecutive failure up to ErrorMaxTTL.
+// Failures caused by the caller's own ctx and ErrCircuitOpen (no loader call)
+// are not cached.
+func (c *cache[K, V]) cacheLoadError(ctx context.Context, k K, err error) {
+	if ctx.Err() != nil || c.closed.Load() || errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+	if errors.Is(err, ErrNotFound) {
+		if c.opt.NegativeTTL > 0 {
+			c.getShard(k).putNegative(k, err, int64(c.opt.NegativeTTL), int64(c.opt.NegativeTTL), false)
+		}
+		return
+	}
+	if c.opt.ErrorTTL > 0 {
+		maxTTL := c.opt.ErrorMaxTTL
+		if maxTTL <= 0 {
+			maxTTL = c.opt.ErrorTTL * defaultErrorBackoffFactor
+		}
+		c.getShard(k).putNegative(k, err, int64(c.opt.ErrorTTL), int64(maxTTL), true)
+	}
+}
+
+// negative returns the cached load error for k, if one has not expired.
+func (s *shard[K, V]) negative(k K) error {
+	var exp int64
+	var err error
+	s.mu.RLock()
+	if e, ok := s.neg[k]; ok {
+		exp, err = e.exp, e.err // putNegative updates e in place
+	}
+	s.mu.RUnlock()
+	if err == nil || s.now() > exp {
+		return nil
+	}
+	return err
+}
+
+// putNegative caches err for k. With backoff, the TTL is base·2^(n-1) for
+// the n-th consecutive failure (capped at maxTTL); the streak is forgotten
+// once the key has gone maxTTL past its last cached error without failing.
+// A shard holds at most cap negative entries; the one that failed least
+// recently makes room for a new key.
+func (s *shard[K, V]) putNegative(k K, err error, base, maxTTL int64, backoff bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e, ok := s.neg[k]
+	if ok {
+		s.unlinkNegativeLocked(e)
+		if now > e.forget {
+			e.failures = 0
+		}
+	} else {
+		s.purgeNegativeLocked(now)
+		if len(s.neg) >= s.cap && s.negTail != nil {
+			s.dropNegativeLocked(s.negTail.key)
+		}
+		if s.neg == nil {
+			s.neg = make(map[K]*negEntry[K])
+		}
+		e = &negEntry[K]{key: k}
+		s.neg[k] = e
+	}
+	s.pushNegativeLocked(e)
+	e.failures++
+
+	ttl := base
+	if backoff {
+		for i := 1; i < e.failures && ttl < maxTTL; i++ {
+			ttl *= 2
+		}
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+	}
+	e.err = err
+	e.exp = now + ttl
+	e.forget = e.exp + maxTTL
+}
+
+// purgeNegativeLocked drops forgotten negative entries from the least
+// recently failed end, stopping at the first one still remembered.
+func (s *shard[K, V]) purgeNegativeLocked(now int64) {
+	for s.negTail != nil && now > s.negTail.forget {
+		s.dropNegativeLocked(s.negTail.key)
+	}
+}
+
+// dropNegativeLocked forgets the cached failure of k, if any.
+func (s *shard[K, V]) dropNegativeLocked(k K) {
+	if e, ok := s.neg[k]; ok {
+		s.unlinkNegativeLocked(e)
+		delete(s.neg, k)
+	}
+}
+
+// clearNegativeLocked forgets every cached failure.
+func (s *shard[K, V]) clearNegativeLocked() {
+	clear(s.neg)
+	s.negHead, s.negTail = nil, nil
+}
+
+func (s *shard[K, V]) pushNegativeLocked(e *negEntry[K]) {
+	e.prev, e.next = nil, s.negHead
+	if s.negHead != nil {
+		s.negHead.prev = e
+	} else {
+		s.negTail = e
+	}
+	s.negHead = e
+}
+
+func (s *shard[K, V]) unlinkNegativeLocked(e *negEntry[K]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		s.negHead = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		s.negTail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
@@ -0,0 +1,246 @@
+package shardcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A loader's ErrNotFound is cached for NegativeTTL; Set clears it early.
+func TestCache_GetOrLoad_NegativeTTL(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:    8,
+		Shards:      1,
+		NegativeTTL: time.Second,
+		Clock:       clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			atomic.AddInt64(&calls, 1)
+			return "", fmt.Errorf("row %s: %w", k, ErrNotFound)
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.GetOrLoad(context.Background(), "k"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("want ErrNotFound, got %v", err)
+		}
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("negative entry must absorb repeats, loader calls=%d", got)
+	}
+
+	clk.add(2 * time.Second) // negative entry expired
+	if _, err := c.GetOrLoad(context.Background(), "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Fatalf("want reload after NegativeTTL, loader calls=%d", got)
+	}
+
+	c.Set("k", "v") // an explicit write clears the cached miss
+	c.Remove("k")
+	if _, err := c.GetOrLoad(context.Background(), "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if got := atomic.LoadInt64(&calls); got != 3 {
+		t.Fatalf("Set/Remove must clear the negative entry, loader calls=%d", got)
+	}
+}
+
+// Other errors are cached with per-key exponential backoff capped at
+// ErrorMaxTTL; a success ends the streak.
+func TestCache_GetOrLoad_ErrorBackoff(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	var fail atomic.Bool
+	fail.Store(true)
+	boom := errors.New("backend down")
+	clk := &fakeClock{}
+	c := New[string, string](Options[string, string]{
+		Capacity:    8,
+		Shards:      1,
+		ErrorTTL:    100 * time.Millisecond,
+		ErrorMaxTTL: 400 * time.Millisecond,
+		Clock:       clk,
+		Loader: func(_ context.Context, k string) (string, error) {
+			atomic.AddInt64(&calls, 1)
+			if fail.Load() {
+				return "", boom
+			}
+			return "v:" + k, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	load := func() error {
+		_, err := c.GetOrLoad(context.Background(), "k")
+		return err
+	}
+
+	// Expected cached TTLs: 100ms, 200ms, 400ms, 400ms (capped).
+	for i, ttl := range []time.Duration{100, 200, 400, 400} {
+		ttl *= time.Millisecond
+		if err := load(); !errors.Is(err, boom) {
+			t.Fatalf("attempt %d: want %v, got %v", i, boom, err)
+		}
+		want := int64(i + 1)
+		clk.add(ttl - time.Millisecond)
+		if err := load(); !errors.Is(err, boom) || atomic.LoadInt64(&calls) != want {
+			t.Fatalf("attempt %d: error must stay cached for %v (calls=%d, err=%v)", i, ttl, atomic.LoadInt64(&calls), err)
+		}
+		clk.add(2 * time.Millisecond)
+	}
+
+	// Once the cached error expires, a success ends the streak.
+	fail.Store(false)
+	if v, err := c.GetOrLoad(context.Background(), "k"); err != nil || v != "v:k" {
+		t.Fatalf("want recovery, got %q err=%v", v, err)
+	}
+}
+
+// GetOrLoadMany reports negatively cached keys without calling BulkLoader.
+func TestCache_GetOrLoadMany_Negative(t *testing.T) {
+	t.Parallel()
+
+	var calls int64
+	clk := &fakeClock{}
+	c := New[int, int](Options[int, int]{
+		Capacity:    16,
+		NegativeTTL: time.Second,
+		Clock:       clk,
+		BulkLoader: func(_ context.Context, keys []int) (map[int]int, error) {
+			atomic.AddInt64(&calls, 1)
+			out := make(map[int]int)
+			for _, k := range keys {
+				if k%2 == 0 {
+					out[k] = k * 10
+				}
+			}
+			return out, nil
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := 0; i < 2; i++ {
+		vals, err := c.GetOrLoadMany(context.Background(), []int{1, 2, 3, 4})
+		var lerr LoadErrors[int]
+		if !errors.As(err, &lerr) || len(lerr) != 2 || !errors.Is(lerr[1], ErrNotFound) || !errors.Is(lerr[3], ErrNotFound) {
+			t.Fatalf("want ErrNotFound for odd keys, got %v", err)
+		}
+		if len(vals) != 2 || vals[2] != 20 || vals[4] != 40 {
+			t.Fatalf("unexpected values %v", vals)
+		}
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("second batch must be served from cache, loader calls=%d", got)
+	}
+}
+
+// An Add that loses to a live entry leaves the cached failure of the key
+// (e.g. from a failed refresh) in place; an Add that inserts clears it.
+func TestCache_Add_KeepsNegativeOnConflict(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, string](Options[string, string]{
+		Capacity: 8,
+		Shards:   1,
+		ErrorTTL: time.Minute,
+		Loader: func(context.Context, string) (string, error) {
+			return "", errors.New("backend down")
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	c.Set("k", "v")
+	if _, err := c.Refresh(context.Background(), "k"); err == nil {
+		t.Fatal("want the refresh to fail")
+	}
+	s := c.(*cache[string, string]).shards[0]
+	if s.negative("k") == nil {
+		t.Fatal("a failed refresh must be cached")
+	}
+
+	if c.Add("k", "other") {
+		t.Fatal("Add must not replace a live entry")
+	}
+	if s.negative("k") == nil {
+		t.Fatal("a failed Add must not clear the cached failure")
+	}
+
+	c.Remove("k")
+	s.putNegative("k", errors.New("again"), int64(time.Minute), int64(time.Minute), false)
+	if !c.Add("k", "new") || s.negative("k") != nil {
+		t.Fatal("an Add that inserts must clear the cached failure")
+	}
+}
+
+// A shard holds at most Capacity negative entries; the key that failed least
+// recently is dropped first.
+func TestCache_Negative_BoundedByRecency(t *testing.T) {
+	t.Parallel()
+
+	c := New[string, string](Options[string, string]{
+		Capacity: 2,
+		Shards:   1,
+		ErrorTTL: time.Minute,
+		Loader: func(context.Context, string) (string, error) {
+			return "", errors.New("backend down")
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx := context.Background()
+	_, _ = c.GetOrLoad(ctx, "a")
+	_, _ = c.GetOrLoad(ctx, "b")
+	_, _ = c.Refresh(ctx, "a") // a fails again, b is now the oldest failure
+	_, _ = c.GetOrLoad(ctx, "c")
+
+	s := c.(*cache[string, string]).shards[0]
+	if len(s.neg) != 2 {
+		t.Fatalf("want 2 negative entries, got %d", len(s.neg))
+	}
+	if s.negative("a") == nil || s.negative("b") != nil || s.negative("c") == nil {
+		t.Fatal("the least recently failed key must be dropped")
+	}
+}
+
+// Concurrent readers of a negative entry race with the load that extends it
+// (run with -race).
+func TestCache_GetOrLoad_NegativeConcurrent(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("backend down")
+	c := New[string, string](Options[string, string]{
+		Capacity:    8,
+		ErrorTTL:    time.Millisecond,
+		ErrorMaxTTL: time.Hour,
+		Loader: func(context.Context, string) (string, error) {
+			return "", boom
+		},
+	})
+	t.Cleanup(func() { _ = c.Close() })
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, err := c.GetOrLoad(context.Background(), "k"); !errors.Is(err, boom) {
+					t.Errorf("want %v, got %v", boom, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
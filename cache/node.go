@@ -1,5 +1,7 @@
 package shardcache
 
+import "sync/atomic"
+
 // node is an intrusive doubly linked list element owned by a shard.
 // It stores the key/value alongside list links and metadata used by
 // eviction policies and TTL/cost accounting.
@@ -15,15 +17,50 @@ type node[K comparable, V any] struct {
 	// Zero means "no TTL".
 	exp int64
 
+	// Sliding (expire-after-access) TTL in ns; 0 = fixed deadline. For such
+	// entries exp is the deadline as of the last write or reclamation check,
+	// and hits only stamp access (under the read lock), so the effective
+	// deadline is max(exp, access+idle).
+	idle   int64
+	access atomic.Int64
+
+	// Time of the last write (UnixNano), used for RefreshAfter.
+	written int64
+
+	// Version of the value, assigned from the shard on every write (> 0).
+	version uint64
+
+	// Duration (ns) of the load that produced val, used by XFetch; 0 if the
+	// value was not loaded.
+	loadTime int64
+
+	// Position in the shard's expiry heap; -1 if the node has no TTL.
+	hidx int
+
 	// Logical "cost" used when MaxCost is enabled.
 	// Entries are evicted until both length and cost limits are satisfied.
 	cost int32
 
+	// revalidating is set while a background reload (SWR or RefreshAfter)
+	// is in flight for this entry, so that only one reload is started.
+	revalidating bool
+
 	// Reserved for policy-specific metadata (e.g., class/segment for 2Q/TinyLFU).
 	// Add fields here when a policy needs to tag nodes without map lookups.
 	// e.g. class uint8
 }
 
+// deadline returns the effective expiration deadline (0 = none), taking
+// hits on sliding-TTL entries into account.
+func (n *node[K, V]) deadline() int64 {
+	if n.idle > 0 {
+		if d := n.access.Load() + n.idle; d > n.exp {
+			return d
+		}
+	}
+	return n.exp
+}
+
 // Key returns the node key (part of policy.Node interface).
 func (n *node[K, V]) Key() K { return n.key }
 
@@ -13,10 +13,12 @@ type EvictReason int
 const (
 	// EvictPolicy — removed by the active eviction policy (e.g., LRU/2Q/TinyLFU).
 	EvictPolicy EvictReason = iota
-	// EvictTTL — expired by TTL (lazy eviction on access).
+	// EvictTTL — expired by TTL (on access or by the background janitor).
 	EvictTTL
 	// EvictCapacity — removed to satisfy capacity/cost limits.
 	EvictCapacity
+	// EvictClosed — released by Close/Shutdown (Options.EvictOnClose).
+	EvictClosed
 )
 
 // Metrics exposes cache-level observability hooks.
@@ -29,6 +31,32 @@ type Metrics interface {
 	// Consider adding ObserveLoad(dur) in the future for Loader timing.
 }
 
+// RefreshMetrics is an optional extension of Metrics: if Options.Metrics
+// implements it, Refresh is called for every reload of a cached entry
+// (RefreshAfter, Refresh, SWR or XFetch revalidation); ok reports whether the
+// loader succeeded. Refreshes are not counted as misses.
+type RefreshMetrics interface {
+	Refresh(ok bool)
+}
+
+// CircuitMetrics is an optional extension of Metrics: if Options.Metrics
+// implements it, Circuit is called on every state change of the loader
+// circuit breaker.
+type CircuitMetrics interface {
+	Circuit(state CircuitState)
+}
+
+// LoadInfo lets Options.LoaderWithInfo control how a loaded value is stored.
+type LoadInfo struct {
+	// TTL of the entry: 0 => DefaultTTL, < 0 => no expiration.
+	TTL time.Duration
+	// Cost of the entry: 0 => Options.Cost (if set).
+	Cost int
+	// NoCache returns the value to the callers without storing it; a cached
+	// entry for the key (e.g. a stale one) is dropped.
+	NoCache bool
+}
+
 // Clock provides time in UnixNano; useful for deterministic tests.
 type Clock interface{ NowUnixNano() int64 }
 
@@ -37,8 +65,10 @@ type Clock interface{ NowUnixNano() int64 }
 //   - nil Policy   => LRU
 //   - Shards <= 0  => auto (rounded up to power of two)
 //   - nil Metrics  => NoopMetrics
+//   - nil Hasher   => seeded maphash
 type Options[K comparable, V any] struct {
 	// Capacity is the entry count limit (used together with MaxCost if set).
+	// By default it is split evenly across shards; see GlobalCapacity.
 	Capacity int
 
 	// Shards defines the number of shards. If 0, an automatic value is chosen
@@ -48,24 +78,112 @@ type Options[K comparable, V any] struct {
 	// Policy is a pluggable eviction policy (LRU/2Q/…); nil => LRU by default.
 	Policy policy.Policy[K, V]
 
+	// DisableReadBuffer makes every hit take the shard write lock and promote
+	// the entry immediately. By default hits are served under the read lock and
+	// their promotions are buffered (lossy) and applied in batches.
+	DisableReadBuffer bool
+
+	// Hasher maps a key to a 64-bit hash used to pick its shard.
+	// nil => hash/maphash.Comparable with a per-cache random seed, which works
+	// for every comparable K and resists hash flooding.
+	Hasher func(k K) uint64
+
 	// TTL & SWR
 	// DefaultTTL applies to Add/Set when per-key TTL is not provided (0 = no TTL).
 	DefaultTTL time.Duration
-	// SWR enables serve-stale-while-revalidate windows (reserved for future use).
+	// ExpireAfterAccess gives entries written without an explicit TTL a
+	// sliding deadline: they expire once not read (Get/GetOrLoad hit) for
+	// this long. It takes precedence over DefaultTTL; 0 disables.
+	ExpireAfterAccess time.Duration
+	// SWR enables serve-stale-while-revalidate: for this long after expiry an
+	// entry is retained, GetOrLoad returns it immediately and starts a single
+	// background reload via Loader. Get still reports such entries as a miss.
 	SWR time.Duration
+	// StaleIfError retains expired entries for this long after expiry as a
+	// fallback: if the GetOrLoad reload fails or times out, the stale value
+	// is returned with an error wrapping ErrStale and the load error. Get
+	// still reports such entries as a miss. ErrNotFound is never masked.
+	StaleIfError time.Duration
+	// RefreshAfter makes GetOrLoad/GetOrLoadMany start a single background
+	// reload of an entry once it is this old (since its last write), while
+	// still serving the current value; on failure the value is kept.
+	// 0 disables. Should be shorter than the TTL to be useful.
+	RefreshAfter time.Duration
+	// XFetchBeta enables probabilistic early refresh (XFetch): GetOrLoad may
+	// start a single background reload of a loaded entry before it expires,
+	// more likely the closer the deadline and the slower its last load was.
+	// 1 is the usual choice; larger values refresh earlier. 0 disables.
+	XFetchBeta float64
+	// TTLJitter shortens every TTL applied on write (Add, Set, SetWithTTL,
+	// loads) by a random fraction in [0, TTLJitter), e.g. 0.1 = up to 10%, so
+	// entries written together do not expire together. 0 disables; max 1.
+	TTLJitter float64
+	// ExpireInterval enables proactive expiration: a background janitor wakes
+	// up at this cadence and reclaims expired entries (firing OnEvict with
+	// EvictTTL). 0 keeps expiration lazy. The janitor is stopped by Close.
+	ExpireInterval time.Duration
 
 	// Cost-based limiting (e.g., bytes). If Cost is non-nil and MaxCost > 0,
 	// the cache evicts until both entry count and total cost limits are satisfied.
+	// MaxCost is a cache-wide budget: shards share it regardless of key
+	// distribution, and evictions are taken from the heaviest shard.
 	Cost    func(v V) int // nil = all entries have equal cost (0)
 	MaxCost int64         // total cost limit; 0 disables cost limiting
 
+	// GlobalCapacity enforces Capacity across all shards (like MaxCost)
+	// instead of splitting it evenly, so skewed keys can use idle shards'
+	// share. It adds a shared atomic counter to every insert and removal.
+	GlobalCapacity bool
+
 	// Loader fetches a value on cache miss. Used by GetOrLoad.
 	Loader func(ctx context.Context, k K) (V, error)
+	// LoaderWithInfo is an alternative to Loader that also decides the TTL,
+	// cost and admission of each loaded value. It takes precedence over Loader.
+	LoaderWithInfo func(ctx context.Context, k K) (V, LoadInfo, error)
+
+	// BulkLoader fetches many keys in one call. Used by GetOrLoadMany; keys
+	// missing from the returned map are reported as ErrNotFound.
+	BulkLoader func(ctx context.Context, keys []K) (map[K]V, error)
+
+	// MaxConcurrentLoads caps loader calls (Loader, LoaderWithInfo and
+	// BulkLoader batches) running at once; further loads wait for a slot
+	// until their ctx is done. 0 = unlimited.
+	MaxConcurrentLoads int
+	// BreakerThreshold opens a circuit breaker after this many consecutive
+	// loader failures (ErrNotFound and cancellations do not count): loads
+	// then fail fast with ErrCircuitOpen until BreakerCooldown (0 = 5s) has
+	// passed, after which a single trial load closes or re-opens it.
+	// 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Negative caching of load failures. A loader error matching ErrNotFound
+	// is cached for NegativeTTL; any other error is cached for ErrorTTL,
+	// doubling on each consecutive failure of the key up to ErrorMaxTTL
+	// (0 = 32×ErrorTTL). Until it expires, GetOrLoad/GetOrLoadMany return the
+	// cached error without calling the loader; Set/Add/Remove clear it.
+	// Each shard keeps at most its entry capacity of failed keys, dropping
+	// the least recently failed. 0 disables the respective caching.
+	NegativeTTL time.Duration
+	ErrorTTL    time.Duration
+	ErrorMaxTTL time.Duration
+
+	// Equal compares values for CompareAndSwap. nil => == on the dynamic
+	// values, which panics if V holds a non-comparable type (slice, map, func).
+	Equal func(a, b V) bool
+
+	// KeyCodec and ValueCodec encode entries for Snapshot/Restore.
+	// nil => GobCodec.
+	KeyCodec   Codec[K]
+	ValueCodec Codec[V]
 
 	// Observability
 	// OnEvict is called on eviction under the shard lock; keep callbacks lightweight.
 	OnEvict func(k K, v V, reason EvictReason)
-	Metrics Metrics
+	// EvictOnClose evicts every entry through OnEvict (EvictClosed) when the
+	// cache is closed, so values holding resources can release them.
+	EvictOnClose bool
+	Metrics      Metrics
 
 	// Clock allows overriding time source (tests). Nil => time.Now().
 	Clock Clock
@@ -0,0 +1,73 @@
+package shardcache
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/IvanBrykalov/shardcache/internal/util"
+)
+
+const (
+	// readStripes is the number of independent ring segments per shard
+	// (power of two). Each read picks a stripe at random, so concurrent
+	// readers of the same hot key spread out instead of sharing a counter.
+	readStripes = 4
+	// readStripeSize is the number of slots per stripe; a full stripe
+	// triggers a drain attempt.
+	readStripeSize = 32
+)
+
+// readStripe is a fixed-size, lossy buffer of recently read nodes.
+// Slots are claimed with an atomic counter; once the stripe is full,
+// further reads are dropped until the next drain.
+type readStripe[K comparable, V any] struct {
+	n   atomic.Uint32
+	buf [readStripeSize]atomic.Pointer[node[K, V]]
+	_   util.CacheLinePad
+}
+
+// readBuffer records hits observed under the shared (read) lock so that the
+// policy promotions they imply can be applied later, in a batch, under the
+// write lock. This lets Get avoid exclusive locking on the hot path
+// (Ristretto/Caffeine style).
+//
+// Concurrency: record is called with the read lock held and drain with the
+// write lock held, so the two never overlap; concurrent records only race on
+// the per-stripe atomics.
+type readBuffer[K comparable, V any] struct {
+	stripes [readStripes]readStripe[K, V]
+}
+
+// record buffers n and reports whether its stripe is full, in which case the
+// caller should try to drain. Reads beyond capacity are dropped; every such
+// reader retries the drain, so a failed TryLock does not stall promotions.
+func (b *readBuffer[K, V]) record(n *node[K, V]) bool {
+	st := &b.stripes[rand.Uint32()&(readStripes-1)]
+	i := st.n.Add(1) - 1
+	if i >= readStripeSize {
+		return true // lossy: dropped, but a drain is due
+	}
+	st.buf[i].Store(n)
+	return i == readStripeSize-1
+}
+
+// drain passes every buffered node to fn in recording order (per stripe)
+// and resets the buffer. Must be called with the shard write lock held.
+func (b *readBuffer[K, V]) drain(fn func(*node[K, V])) {
+	for i := range b.stripes {
+		st := &b.stripes[i]
+		cnt := st.n.Load()
+		if cnt == 0 {
+			continue
+		}
+		if cnt > readStripeSize {
+			cnt = readStripeSize
+		}
+		for j := uint32(0); j < cnt; j++ {
+			if n := st.buf[j].Swap(nil); n != nil {
+				fn(n)
+			}
+		}
+		st.n.Store(0)
+	}
+}
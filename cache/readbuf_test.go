@@ -0,0 +1,133 @@
+package shardcache
+
+import "testing"
+
+// lruShard returns the only shard of a single-shard cache.
+func lruShard(t *testing.T, opt Options[string, int]) (Cache[string, int], *shard[string, int]) {
+	t.Helper()
+	opt.Shards = 1
+	c := New[string, int](opt)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, c.(*cache[string, int]).shards[0]
+}
+
+// listKeys walks the shard list MRU→LRU, checking the back links.
+func listKeys(t *testing.T, s *shard[string, int]) []string {
+	t.Helper()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	var prev *node[string, int]
+	for n := s.head; n != nil; n = n.next {
+		if n.prev != prev {
+			t.Fatalf("broken list at %q", n.key)
+		}
+		keys = append(keys, n.key)
+		prev = n
+	}
+	if prev != s.tail || len(keys) != s.len {
+		t.Fatalf("list has %d nodes, shard len %d", len(keys), s.len)
+	}
+	return keys
+}
+
+// A buffered hit does not move the entry until the next write drains it.
+func TestReadBuffer_AppliedOnWrite(t *testing.T) {
+	t.Parallel()
+
+	c, s := lruShard(t, Options[string, int]{Capacity: 3})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	c.Get("a")
+	if keys := listKeys(t, s); keys[0] != "c" {
+		t.Fatalf("hit must be buffered, list %v", keys)
+	}
+	c.Set("d", 4) // drains (a becomes MRU), then evicts the LRU: b
+	if c.Contains("b") || !c.Contains("a") {
+		t.Fatalf("buffered hit must be applied before eviction, list %v", listKeys(t, s))
+	}
+}
+
+// A full stripe drops further reads and reports that a drain is due; Get
+// then drains without waiting for a write.
+func TestReadBuffer_OverflowDrains(t *testing.T) {
+	t.Parallel()
+
+	var b readBuffer[string, int]
+	n := &node[string, int]{key: "k"}
+	full := 0
+	for i := 0; i < 2*readStripes*readStripeSize; i++ {
+		if b.record(n) {
+			full++
+		}
+	}
+	if full < readStripes*readStripeSize {
+		t.Fatalf("reads beyond capacity must request a drain, got %d", full)
+	}
+	drained := 0
+	b.drain(func(*node[string, int]) { drained++ })
+	if drained != readStripes*readStripeSize {
+		t.Fatalf("drain must apply exactly the buffered reads, got %d", drained)
+	}
+
+	c, s := lruShard(t, Options[string, int]{Capacity: 4})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	for i := 0; i < 2*readStripes*readStripeSize; i++ {
+		c.Get("a")
+	}
+	if keys := listKeys(t, s); keys[0] != "a" {
+		t.Fatalf("overflowing reads must trigger a drain, list %v", keys)
+	}
+}
+
+// Buffered nodes that were removed or replaced are skipped by the drain.
+func TestReadBuffer_SkipsDeadNodes(t *testing.T) {
+	t.Parallel()
+
+	c, s := lruShard(t, Options[string, int]{Capacity: 4})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	s.mu.RLock()
+	old := s.m["a"]
+	s.mu.RUnlock()
+
+	c.Get("a")    // buffered
+	c.Remove("a") // Remove does not drain
+	c.Set("c", 3) // drains the removed node
+	if keys := listKeys(t, s); len(keys) != 2 {
+		t.Fatalf("removed node must not be relinked, list %v", keys)
+	}
+
+	c.Set("a", 4) // a new node for the same key
+	s.mu.RLock()
+	s.reads.record(old) // a stale read of the replaced node
+	s.mu.RUnlock()
+	c.Set("d", 5)
+	keys := listKeys(t, s)
+	if len(keys) != 4 || keys[1] != "a" {
+		t.Fatalf("replaced node must be skipped, list %v", keys)
+	}
+	if v, _ := c.Peek("a"); v != 4 {
+		t.Fatalf("want the new value 4, got %d", v)
+	}
+}
+
+// With DisableReadBuffer every hit promotes immediately.
+func TestReadBuffer_Disabled(t *testing.T) {
+	t.Parallel()
+
+	c, s := lruShard(t, Options[string, int]{Capacity: 3, DisableReadBuffer: true})
+	if s.reads != nil {
+		t.Fatal("read buffer must not be allocated")
+	}
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	if keys := listKeys(t, s); keys[0] != "a" {
+		t.Fatalf("hit must promote synchronously, list %v", keys)
+	}
+}
@@ -1,6 +1,8 @@
 package shardcache
 
 import (
+	"math"
+	"math/rand/v2"
 	"sync"
 	"time"
 
@@ -8,6 +10,16 @@ import (
 	"github.com/IvanBrykalov/shardcache/policy"
 )
 
+// lookupState classifies the result of a read-through lookup.
+type lookupState uint8
+
+const (
+	lookupMiss    lookupState = iota // absent or expired beyond the SWR window
+	lookupFresh                      // present and not expired
+	lookupStale                      // expired but within the SWR window
+	lookupExpired                    // a miss, but the value is kept for StaleIfError
+)
+
 // shard is an independent partition of the cache with its own lock, map,
 // and an intrusive doubly linked list (head=MRU, tail=LRU).
 type shard[K comparable, V any] struct {
@@ -20,34 +32,56 @@ type shard[K comparable, V any] struct {
 	cost    int64       // total cost (if MaxCost is enabled)
 	cap     int         // per-shard entry capacity
 	maxCost int64       // per-shard cost limit (0 = disabled)
+	budget  *budget     // cache-wide limits shared by all shards (nil = none)
+	expq    expiryHeap[K, V]
+	neg     map[K]*negEntry[K] // cached loader failures (lazily allocated)
+	negHead *negEntry[K]       // most recently failed
+	negTail *negEntry[K]       // least recently failed
+	version uint64             // last entry version handed out
+
+	// reads buffers hits taken under the read lock (nil if disabled).
+	reads *readBuffer[K, V]
 
 	// Policy and options (policy uses hooks to manipulate the list).
-	pol policy.ShardPolicy[K, V]
-	opt Options[K, V]
+	pol     policy.ShardPolicy[K, V]
+	opt     Options[K, V]
+	refresh RefreshMetrics // opt.Metrics if it implements it, else NoopMetrics
 
 	// ---- hot counters (separate cache lines to avoid false sharing) ----
-	_      util.CacheLinePad
-	hits   util.PaddedAtomicInt64
-	misses util.PaddedAtomicInt64
-	evicts util.PaddedAtomicUint64
+	_           util.CacheLinePad
+	hits        util.PaddedAtomicInt64
+	misses      util.PaddedAtomicInt64
+	evicts      [evictReasonCount]util.PaddedAtomicUint64
+	removes     util.PaddedAtomicUint64
+	loads       util.PaddedAtomicUint64
+	loadErrs    util.PaddedAtomicUint64
+	loadNanos   util.PaddedAtomicInt64
+	refreshes   util.PaddedAtomicUint64
+	refreshErrs util.PaddedAtomicUint64
+
+	// lock-free mirrors of len/cost, maintained only when budget != nil,
+	// used to pick the heaviest shard for cross-shard eviction.
+	lenGauge  util.PaddedAtomicInt64
+	costGauge util.PaddedAtomicInt64
 }
 
-// newShard initializes a shard with per-shard capacity, policy factory, and options.
-// maxCost is derived by splitting opt.MaxCost evenly across shards.
-func newShard[K comparable, V any](capacity int, pol policy.Policy[K, V], opt Options[K, V]) *shard[K, V] {
+// newShard initializes a shard with its entry and cost limits, policy factory,
+// and options. sizeHint presizes the map; b is the shared cache-wide budget
+// (nil if no cross-shard limit is configured).
+func newShard[K comparable, V any](capacity, sizeHint int, maxCost int64, b *budget, pol policy.Policy[K, V], opt Options[K, V]) *shard[K, V] {
 	s := &shard[K, V]{
-		m:   make(map[K]*node[K, V], capacity),
-		cap: capacity,
-		opt: opt,
+		m:       make(map[K]*node[K, V], sizeHint),
+		cap:     capacity,
+		maxCost: maxCost,
+		budget:  b,
+		opt:     opt,
+		refresh: NoopMetrics{},
 	}
-
-	// Split global MaxCost across shards (ceil division).
-	if opt.MaxCost > 0 {
-		shards := opt.Shards
-		if shards <= 0 {
-			shards = util.ReasonableShardCount()
-		}
-		s.maxCost = (opt.MaxCost + int64(shards) - 1) / int64(shards)
+	if m, ok := opt.Metrics.(RefreshMetrics); ok {
+		s.refresh = m
+	}
+	if !opt.DisableReadBuffer {
+		s.reads = &readBuffer[K, V]{}
 	}
 
 	// Wrap this shard with policy hooks.
@@ -57,17 +91,23 @@ func newShard[K comparable, V any](capacity int, pol policy.Policy[K, V], opt Op
 }
 
 // Add inserts a NEW entry (no update) as MRU via policy hooks.
-// ttl is an absolute UnixNano deadline (0 = no TTL); cost is the logical weight (0 = equal).
-// Returns false if the key already exists.
-func (s *shard[K, V]) Add(k K, v V, ttl int64, cost int32) bool {
+// ttl is an absolute UnixNano deadline (0 = no TTL); idle > 0 makes it a
+// sliding deadline extended by idle ns on every hit; cost is the logical
+// weight (0 = equal). Returns false if the key already exists.
+func (s *shard[K, V]) Add(k K, v V, ttl, idle int64, cost int32) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.drainReadsLocked()
 
-	if _, exists := s.m[k]; exists {
-		return false
+	if old, exists := s.m[k]; exists {
+		// A stale entry retained for SWR counts as absent.
+		if !s.expiredLocked(old) {
+			return false
+		}
+		s.evictNode(old, EvictTTL)
 	}
-	n := &node[K, V]{key: k, val: v, exp: ttl, cost: cost}
-	s.m[k] = n
+	s.dropNegativeLocked(k)
+	n := s.newNodeLocked(k, v, ttl, idle, cost)
 
 	// Let the policy place/promote (and optionally suggest an eviction).
 	if ev := s.pol.OnAdd(n); ev != nil {
@@ -80,77 +120,256 @@ func (s *shard[K, V]) Add(k K, v V, ttl int64, cost int32) bool {
 }
 
 // Set inserts or updates an entry and promotes it according to the policy.
-func (s *shard[K, V]) Set(k K, v V, ttl int64, cost int32) {
+// ttl, idle and cost are as for Add.
+func (s *shard[K, V]) Set(k K, v V, ttl, idle int64, cost int32) {
+	s.set(k, v, ttl, idle, cost, 0)
+}
+
+// set is Set for a loaded value: loadTime is the duration (ns) of the load
+// that produced v, used by XFetch. 0 keeps the previously recorded duration.
+func (s *shard[K, V]) set(k K, v V, ttl, idle int64, cost int32, loadTime int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.drainReadsLocked()
+	s.dropNegativeLocked(k)
 
 	if n, ok := s.m[k]; ok {
-		// In-place update: adjust cost delta and promote.
-		oldCost := int64(n.cost)
-		n.val = v
-		n.exp = ttl
-		n.cost = cost
-		s.cost += int64(cost) - oldCost
-
-		s.pol.OnUpdate(n)
-		s.enforceLimitsLocked()
-		return
+		s.updateLocked(n, v, ttl, idle, cost, loadTime)
+	} else {
+		s.insertLocked(k, v, ttl, idle, cost, loadTime)
 	}
+	s.enforceLimitsLocked()
+}
 
-	// New entry path.
-	n := &node[K, V]{key: k, val: v, exp: ttl, cost: cost}
-	s.m[k] = n
+// updateLocked rewrites n in place (adjusting the cost delta) and promotes
+// it. The caller enforces the limits afterwards.
+func (s *shard[K, V]) updateLocked(n *node[K, V], v V, ttl, idle int64, cost int32, loadTime int64) {
+	oldCost := int64(n.cost)
+	n.val = v
+	n.exp = ttl
+	n.idle = idle
+	n.written = s.now()
+	n.version = s.nextVersionLocked()
+	n.cost = cost
+	n.revalidating = false
+	if loadTime > 0 {
+		n.loadTime = loadTime
+	}
+	s.account(0, int64(cost)-oldCost)
+	s.expq.track(n)
+
+	s.pol.OnUpdate(n)
+}
+
+// insertLocked adds a new entry for k and lets the policy place it (and
+// possibly evict). The caller enforces the limits afterwards.
+func (s *shard[K, V]) insertLocked(k K, v V, ttl, idle int64, cost int32, loadTime int64) *node[K, V] {
+	n := s.newNodeLocked(k, v, ttl, idle, cost)
+	n.loadTime = loadTime
 
 	if ev := s.pol.OnAdd(n); ev != nil {
 		s.evictNode(ev.(*node[K, V]), EvictPolicy)
 	}
-	s.enforceLimitsLocked()
+	return n
 }
 
 // Get returns the value and promotes the entry according to the policy.
-// TTL: if expired, the entry is evicted and a miss is returned.
+// TTL: if expired, a miss is returned. The entry is evicted unless it is
+// still inside the SWR window, in which case it is kept for GetOrLoad.
+//
+// Fresh hits and plain misses are served under the read lock; only expired
+// entries (and a disabled read buffer) take the write lock.
 func (s *shard[K, V]) Get(k K) (V, bool) {
+	v, _, ok := s.GetWithVersion(k)
+	return v, ok
+}
+
+// GetWithVersion is Get that also returns the version of the entry.
+func (s *shard[K, V]) GetWithVersion(k K) (V, uint64, bool) {
+	if v, ver, hit, done := s.getShared(k, false, 0); done {
+		return v, ver, hit
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.drainReadsLocked()
 
 	n, ok := s.m[k]
 	if !ok {
 		s.misses.Add(1)
 		s.opt.Metrics.Miss()
 		var zero V
-		return zero, false
+		return zero, 0, false
 	}
 	if s.expiredLocked(n) {
-		s.evictNode(n, EvictTTL)
+		if !s.staleLocked(n) {
+			s.evictNode(n, EvictTTL)
+		}
 		s.misses.Add(1)
 		s.opt.Metrics.Miss()
 		var zero V
-		return zero, false
+		return zero, 0, false
 	}
 
+	s.touch(n)
 	s.pol.OnGet(n)
 	s.hits.Add(1)
 	s.opt.Metrics.Hit()
-	return n.val, true
+	return n.val, n.version, true
+}
+
+// lookup is the read-through variant of Get used by GetOrLoad.
+// Fresh entries behave as in Get. An expired entry that is still inside the
+// SWR window is returned as lookupStale; revalidate is true only for the first
+// caller that observes it, so exactly one background reload is started.
+// Past SWR but inside StaleIfError, the entry is a miss (lookupExpired) whose
+// value is returned as a fallback for a failing load. A fresh entry older than
+// RefreshAfter, or picked for early refresh by XFetch, also reports revalidate
+// (once) to start a background refresh.
+func (s *shard[K, V]) lookup(k K) (v V, st lookupState, revalidate bool) {
+	gap := s.xfetchGap()
+	if v, _, hit, done := s.getShared(k, true, gap); done {
+		if hit {
+			return v, lookupFresh, false
+		}
+		return v, lookupMiss, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	n, ok := s.m[k]
+	if ok && s.expiredLocked(n) {
+		switch {
+		case !s.staleLocked(n):
+			s.evictNode(n, EvictTTL)
+			ok = false
+		case s.swrLocked(n):
+			// Serve stale: count as a hit but do not promote.
+			revalidate = !n.revalidating
+			n.revalidating = true
+			s.hits.Add(1)
+			s.opt.Metrics.Hit()
+			return n.val, lookupStale, revalidate
+		default:
+			s.misses.Add(1)
+			s.opt.Metrics.Miss()
+			return n.val, lookupExpired, false
+		}
+	}
+	if !ok {
+		s.misses.Add(1)
+		s.opt.Metrics.Miss()
+		return v, lookupMiss, false
+	}
+
+	if s.refreshDueLocked(n, gap) {
+		revalidate = true
+		n.revalidating = true
+	}
+	s.touch(n)
+	s.pol.OnGet(n)
+	s.hits.Add(1)
+	s.opt.Metrics.Hit()
+	return n.val, lookupFresh, revalidate
+}
+
+// getShared is the read-lock fast path of Get/lookup. It resolves plain misses
+// and fresh hits, recording hits in the read buffer instead of promoting them
+// in place. done=false means the caller must retry under the write lock
+// (read buffer disabled, the entry is expired, or refresh is set and a
+// RefreshAfter reload is due).
+func (s *shard[K, V]) getShared(k K, refresh bool, gap float64) (v V, ver uint64, hit, done bool) {
+	if s.reads == nil {
+		return v, 0, false, false
+	}
+
+	s.mu.RLock()
+	n, ok := s.m[k]
+	if ok && (s.expiredLocked(n) || refresh && s.refreshDueLocked(n, gap)) {
+		s.mu.RUnlock()
+		return v, 0, false, false
+	}
+	full := false
+	if ok {
+		v, ver = n.val, n.version
+		s.touch(n)
+		full = s.reads.record(n)
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		s.misses.Add(1)
+		s.opt.Metrics.Miss()
+		return v, 0, false, true
+	}
+	s.hits.Add(1)
+	s.opt.Metrics.Hit()
+	if full && s.mu.TryLock() {
+		s.drainReadsLocked()
+		s.mu.Unlock()
+	}
+	return v, ver, true, true
+}
+
+// endRevalidate clears the in-progress revalidation mark for k, allowing a
+// later stale read to retry after a failed background load.
+func (s *shard[K, V]) endRevalidate(k K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := s.m[k]; ok {
+		n.revalidating = false
+	}
 }
 
 // Remove deletes an entry by key. Returns true if the entry existed.
 func (s *shard[K, V]) Remove(k K) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.dropNegativeLocked(k)
 
 	n, ok := s.m[k]
 	if !ok {
 		return false
 	}
-	s.pol.OnRemove(n)
-	s.removeNode(n)
-	delete(s.m, k)
-	// Note: explicit Remove is not counted as an eviction in metrics;
-	// add a dedicated "deletes" counter if needed.
+	s.deleteLocked(n)
+	// Explicit Remove is not an eviction; it has its own counter.
+	s.removes.Add(1)
 	return true
 }
 
+// discard drops the entry for k (LoadInfo.NoCache) without counting it as a
+// Remove or an eviction. Like a stored load, it clears a cached failure.
+func (s *shard[K, V]) discard(k K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+	s.dropNegativeLocked(k)
+
+	if n, ok := s.m[k]; ok {
+		s.deleteLocked(n)
+	}
+}
+
+// resize installs new per-shard limits, attaching the shard to b if it is
+// not accounted in a budget yet, and evicts until the limits hold.
+func (s *shard[K, V]) resize(capacity int, maxCost int64, b *budget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	s.cap = capacity
+	s.maxCost = maxCost
+	if s.budget == nil && b != nil {
+		s.budget = b
+		b.add(s.len, s.cost)
+		s.lenGauge.Store(int64(s.len))
+		s.costGauge.Store(s.cost)
+	}
+	s.enforceLimitsLocked()
+}
+
 // Len returns the number of resident entries in this shard.
 func (s *shard[K, V]) Len() int {
 	s.mu.RLock()
@@ -160,11 +379,98 @@ func (s *shard[K, V]) Len() int {
 
 // -------------------- internals (mu held) --------------------
 
+// newNodeLocked creates a node for k and registers it in the map and the
+// expiry index. The caller hands it to the policy for list placement.
+func (s *shard[K, V]) newNodeLocked(k K, v V, ttl, idle int64, cost int32) *node[K, V] {
+	n := &node[K, V]{key: k, val: v, exp: ttl, idle: idle, written: s.now(), version: s.nextVersionLocked(), cost: cost, hidx: -1}
+	s.m[k] = n
+	s.expq.track(n)
+	return n
+}
+
+// nextVersionLocked returns a new entry version. Versions increase
+// monotonically per shard, so a key never sees the same version twice.
+func (s *shard[K, V]) nextVersionLocked() uint64 {
+	s.version++
+	return s.version
+}
+
+// drainReadsLocked applies buffered hits to the policy, skipping nodes that
+// were removed (or replaced) since they were recorded.
+func (s *shard[K, V]) drainReadsLocked() {
+	if s.reads == nil {
+		return
+	}
+	s.reads.drain(func(n *node[K, V]) {
+		if s.m[n.key] == n {
+			s.pol.OnGet(n)
+		}
+	})
+}
+
 func (s *shard[K, V]) expiredLocked(n *node[K, V]) bool {
 	if n.exp == 0 {
 		return false
 	}
-	return s.now() > n.exp
+	return s.now() > n.deadline()
+}
+
+// touch extends a sliding-TTL entry on a hit. Safe under the read lock.
+func (s *shard[K, V]) touch(n *node[K, V]) {
+	if n.idle > 0 {
+		n.access.Store(s.now())
+	}
+}
+
+// refreshDueLocked reports whether a fresh entry should be reloaded in the
+// background and no reload is in flight for it yet: it is older than
+// RefreshAfter, or XFetch fires, i.e. now + loadTime·gap reaches its deadline
+// (gap is a per-lookup draw from xfetchGap, 0 = disabled).
+func (s *shard[K, V]) refreshDueLocked(n *node[K, V], gap float64) bool {
+	if n.revalidating {
+		return false
+	}
+	now := s.now()
+	if s.opt.RefreshAfter > 0 && now-n.written >= int64(s.opt.RefreshAfter) {
+		return true
+	}
+	if gap <= 0 || n.exp == 0 || n.loadTime <= 0 {
+		return false
+	}
+	return float64(now)+gap*float64(n.loadTime) >= float64(n.deadline())
+}
+
+// xfetchGap draws β·(−ln r), r ∈ (0,1], the XFetch scale factor applied to an
+// entry's load time. It returns 0 if XFetch is disabled.
+func (s *shard[K, V]) xfetchGap() float64 {
+	if s.opt.XFetchBeta <= 0 {
+		return 0
+	}
+	return -s.opt.XFetchBeta * math.Log(1-rand.Float64())
+}
+
+// staleLocked reports whether an expired entry is still retained, i.e.
+// within the SWR or StaleIfError window.
+func (s *shard[K, V]) staleLocked(n *node[K, V]) bool {
+	return s.withinLocked(n, s.graceLocked())
+}
+
+// swrLocked reports whether an expired entry is within the SWR window.
+func (s *shard[K, V]) swrLocked(n *node[K, V]) bool {
+	return s.withinLocked(n, int64(s.opt.SWR))
+}
+
+func (s *shard[K, V]) withinLocked(n *node[K, V], window int64) bool {
+	if window <= 0 || n.exp == 0 {
+		return false
+	}
+	return s.now() <= n.deadline()+window
+}
+
+// graceLocked returns how long (ns) expired entries are retained past their
+// deadline before they become eligible for reclamation.
+func (s *shard[K, V]) graceLocked() int64 {
+	return max(int64(s.opt.SWR), int64(s.opt.StaleIfError), 0)
 }
 
 func (s *shard[K, V]) now() int64 {
@@ -185,8 +491,7 @@ func (s *shard[K, V]) insertFront(n *node[K, V]) {
 	if s.tail == nil {
 		s.tail = n
 	}
-	s.len++
-	s.cost += int64(n.cost)
+	s.account(1, int64(n.cost))
 }
 
 // moveToFront promotes n to MRU in O(1).
@@ -231,22 +536,37 @@ func (s *shard[K, V]) removeNode(n *node[K, V]) {
 		s.tail = n.prev
 	}
 	n.prev, n.next = nil, nil
-	s.len--
-	s.cost -= int64(n.cost)
-	if s.cost < 0 {
-		s.cost = 0
+	s.account(-1, -int64(n.cost))
+}
+
+// account applies a delta to the shard's len/cost and, if a cache-wide
+// budget is configured, to the shared totals and this shard's gauges.
+func (s *shard[K, V]) account(dlen int, dcost int64) {
+	s.len += dlen
+	s.cost += dcost
+	if b := s.budget; b != nil {
+		b.add(dlen, dcost)
+		s.lenGauge.Store(int64(s.len))
+		s.costGauge.Store(s.cost)
 	}
 }
 
 // back returns the current LRU node in O(1).
 func (s *shard[K, V]) back() *node[K, V] { return s.tail }
 
-// evictNode removes the node, updates metrics/counters, and calls OnEvict.
-func (s *shard[K, V]) evictNode(n *node[K, V], reason EvictReason) {
+// deleteLocked notifies the policy and drops n from the list, the map and
+// the expiry index.
+func (s *shard[K, V]) deleteLocked(n *node[K, V]) {
 	s.pol.OnRemove(n)
 	s.removeNode(n)
+	s.expq.untrack(n)
 	delete(s.m, n.key)
-	s.evicts.Add(1)
+}
+
+// evictNode removes the node, updates metrics/counters, and calls OnEvict.
+func (s *shard[K, V]) evictNode(n *node[K, V], reason EvictReason) {
+	s.deleteLocked(n)
+	s.evicts[reason].Add(1)
 	s.opt.Metrics.Evict(reason)
 	if cb := s.opt.OnEvict; cb != nil {
 		// Note: calling callbacks under the lock is safer but may add latency.
@@ -255,22 +575,19 @@ func (s *shard[K, V]) evictNode(n *node[K, V], reason EvictReason) {
 	}
 }
 
-// enforceLimitsLocked evicts LRU items until both count and cost limits are satisfied.
+// enforceLimitsLocked evicts policy-chosen victims until both count and cost
+// limits are satisfied.
 func (s *shard[K, V]) enforceLimitsLocked() {
 	// Count limit
 	for s.len > s.cap {
-		if tail := s.back(); tail != nil {
-			s.evictNode(tail, EvictPolicy)
-		} else {
+		if !s.evictVictimLocked(EvictPolicy) {
 			break
 		}
 	}
 	// Cost limit
 	if s.maxCost > 0 {
 		for s.cost > s.maxCost {
-			if tail := s.back(); tail != nil {
-				s.evictNode(tail, EvictCapacity)
-			} else {
+			if !s.evictVictimLocked(EvictCapacity) {
 				break
 			}
 		}
@@ -278,6 +595,17 @@ func (s *shard[K, V]) enforceLimitsLocked() {
 	s.opt.Metrics.Size(s.len, s.cost)
 }
 
+// evictVictimLocked evicts the node selected by the policy's Victim.
+// Returns false if the policy had nothing to offer.
+func (s *shard[K, V]) evictVictimLocked(reason EvictReason) bool {
+	v := s.pol.Victim()
+	if v == nil {
+		return false
+	}
+	s.evictNode(v.(*node[K, V]), reason)
+	return true
+}
+
 // -------------------- policy hooks --------------------
 
 // shardHooks adapts the shard's list operations to policy.Hooks.
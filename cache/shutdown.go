@@ -0,0 +1,126 @@
+package shardcache
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrClosed is returned by error-returning APIs (GetOrLoad, GetOrLoadMany,
+// Refresh, Snapshot, Restore, ...) once Close or Shutdown has been called.
+var ErrClosed = errorsNew("cache: closed")
+
+// Shutdown closes the cache: new operations are rejected (ErrClosed), the
+// background workers are stopped, and loads already running are awaited,
+// including the storing of their results, until ctx is done. Loads still
+// running at that point have their context cancelled and Shutdown returns
+// ctx.Err() without waiting for them; whatever they return is not stored.
+// With Options.EvictOnClose all entries are then evicted through OnEvict
+// with EvictClosed.
+//
+// Shutdown is idempotent; later calls only wait for the remaining loads.
+// It must not be called from a Loader (it would wait for its own load until
+// ctx is done), nor from OnEvict or Cost, which may run while a loaded value
+// is being stored.
+func (c *cache[K, V]) Shutdown(ctx context.Context) error {
+	c.beginClose()
+
+	var err error
+	select {
+	case <-c.loads.idle:
+	case <-ctx.Done():
+		c.loads.cancel()
+		err = ctx.Err()
+	}
+
+	c.endClose()
+	return err
+}
+
+// beginClose marks the cache closed (once), stops admitting loads and waits
+// for the background workers.
+func (c *cache[K, V]) beginClose() {
+	if c.closed.CompareAndSwap(false, true) {
+		close(c.stop)
+		c.loads.close()
+	}
+	c.wg.Wait()
+}
+
+// endClose waits for the stores in progress and applies EvictOnClose before
+// any later store (which then sees closed) can run.
+func (c *cache[K, V]) endClose() {
+	c.storing.Lock()
+	defer c.storing.Unlock()
+	if c.opt.EvictOnClose {
+		for _, s := range c.shards {
+			s.evictAll(EvictClosed)
+		}
+	}
+}
+
+// loadTracker counts loads in flight, from the loader call until the result
+// is stored, so that Shutdown can wait for them. Once closed it admits no
+// new loads.
+type loadTracker struct {
+	ctx    context.Context // parent of every tracked load; cancelled by cancel
+	cancel context.CancelFunc
+	idle   chan struct{} // closed once closed and no load is running
+
+	mu     sync.Mutex
+	n      int
+	closed bool
+}
+
+func newLoadTracker() *loadTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &loadTracker{ctx: ctx, cancel: cancel, idle: make(chan struct{})}
+}
+
+// start admits one load and returns ctx, additionally cancelled when
+// Shutdown gives up waiting. The caller must call done exactly once.
+// Returns ErrClosed after close.
+func (t *loadTracker) start(ctx context.Context) (context.Context, func(), error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ctx, nil, ErrClosed
+	}
+	t.n++
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		t.mu.Lock()
+		t.n--
+		if t.closed && t.n == 0 {
+			close(t.idle)
+		}
+		t.mu.Unlock()
+	}, nil
+}
+
+// close stops admitting loads; idle is closed once the running ones finish.
+func (t *loadTracker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	if t.n == 0 {
+		close(t.idle)
+	}
+}
+
+// evictAll evicts every entry with reason and drops negative entries.
+func (s *shard[K, V]) evictAll(reason EvictReason) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	for _, n := range s.m {
+		s.evictNode(n, reason)
+	}
+	s.clearNegativeLocked()
+	s.opt.Metrics.Size(s.len, s.cost)
+}
@@ -0,0 +1,381 @@
+package shardcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// Codec encodes keys or values for Snapshot and decodes them in Restore.
+// Name identifies the encoding in the snapshot header, so a file written
+// with one codec is rejected by a cache configured with another.
+type Codec[T any] interface {
+	Name() string
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// GobCodec encodes with encoding/gob. It is the default codec.
+type GobCodec[T any] struct{}
+
+// Name implements Codec.
+func (GobCodec[T]) Name() string { return "gob" }
+
+// Marshal implements Codec.
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Codec.
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// JSONCodec encodes with encoding/json.
+type JSONCodec[T any] struct{}
+
+// Name implements Codec.
+func (JSONCodec[T]) Name() string { return "json" }
+
+// Marshal implements Codec.
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+var (
+	// ErrSnapshotCorrupt is returned by Restore for truncated or damaged input
+	// (bad magic, checksum mismatch, malformed records).
+	ErrSnapshotCorrupt = errorsNew("cache: snapshot is corrupt")
+	// ErrSnapshotVersion is returned by Restore for an unsupported format version.
+	ErrSnapshotVersion = errorsNew("cache: unsupported snapshot version")
+	// ErrSnapshotCodec is returned by Restore when the snapshot was written
+	// with different key/value codecs than the cache is configured with.
+	ErrSnapshotCodec = errorsNew("cache: snapshot codec mismatch")
+)
+
+// Snapshot file layout (integers are unsigned varints unless noted):
+//
+//	magic "SCSN" | version (uint16 LE) | key codec name | value codec name
+//	{ 0x01 | key bytes | value bytes | remaining TTL ns (0 = none) | cost |
+//	  sliding TTL ns (0 = fixed deadline) }*
+//	0x00 | record count | CRC-32C of all preceding bytes (uint32 LE)
+//
+// Byte strings are length-prefixed. Records are interleaved across shards by
+// recency rank, like ByRecency but coldest first: every shard's LRU-most
+// rank before the next, ending with every shard's MRU entry. Replaying them
+// in order approximately restores recency, even when the restoring cache
+// shards keys differently (e.g. another maphash seed).
+const (
+	snapshotMagic   = "SCSN"
+	snapshotVersion = 1
+
+	recordTag = 0x01
+	endTag    = 0x00
+
+	// maxSnapshotBlob bounds a single length prefix so corrupt input fails
+	// instead of triggering a huge allocation.
+	maxSnapshotBlob = 1 << 30
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// snapshotEntry is one resident entry captured under the shard lock.
+type snapshotEntry[K comparable, V any] struct {
+	key  K
+	val  V
+	ttl  int64 // remaining ns; 0 = no TTL
+	idle int64 // sliding TTL in ns; 0 = fixed deadline
+	cost int32
+}
+
+// Snapshot writes all live entries to w. Shards are captured one at a time,
+// so the result is consistent per shard but not across the whole cache; all
+// of them are held in memory until written, to interleave them by recency.
+func (c *cache[K, V]) Snapshot(w io.Writer) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	kc, vc := c.codecs()
+	h := crc32.New(crcTable)
+	sw := &snapWriter{w: bufio.NewWriter(io.MultiWriter(w, h))}
+
+	sw.raw([]byte(snapshotMagic))
+	sw.raw(binary.LittleEndian.AppendUint16(nil, snapshotVersion))
+	sw.bytes([]byte(kc.Name()))
+	sw.bytes([]byte(vc.Name()))
+
+	shards := make([][]snapshotEntry[K, V], len(c.shards))
+	ranks := 0
+	for i, s := range c.shards {
+		shards[i] = s.snapshot()
+		ranks = max(ranks, len(shards[i]))
+	}
+	var count uint64
+	for rank := ranks - 1; rank >= 0; rank-- { // rank 0 = MRU
+		for _, es := range shards {
+			i := len(es) - 1 - rank
+			if i < 0 {
+				continue
+			}
+			if err := writeRecord(sw, es[i], kc, vc); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	sw.raw([]byte{endTag})
+	sw.uvarint(count)
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	// The checksum itself is written past the hashed stream.
+	_, err := w.Write(binary.LittleEndian.AppendUint32(nil, h.Sum32()))
+	return err
+}
+
+// writeRecord encodes e and writes it as one record.
+func writeRecord[K comparable, V any](sw *snapWriter, e snapshotEntry[K, V], kc Codec[K], vc Codec[V]) error {
+	kb, err := kc.Marshal(e.key)
+	if err != nil {
+		return fmt.Errorf("cache: snapshot key: %w", err)
+	}
+	vb, err := vc.Marshal(e.val)
+	if err != nil {
+		return fmt.Errorf("cache: snapshot value: %w", err)
+	}
+	sw.raw([]byte{recordTag})
+	sw.bytes(kb)
+	sw.bytes(vb)
+	sw.uvarint(uint64(e.ttl))
+	sw.uvarint(uint64(e.cost))
+	sw.uvarint(uint64(e.idle))
+	return nil
+}
+
+// snapWriter writes snapshot fields, remembering the first error.
+type snapWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (sw *snapWriter) raw(b []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(b)
+	}
+}
+
+func (sw *snapWriter) uvarint(x uint64) { sw.raw(binary.AppendUvarint(nil, x)) }
+
+func (sw *snapWriter) bytes(b []byte) {
+	sw.uvarint(uint64(len(b)))
+	sw.raw(b)
+}
+
+// Restore reads a snapshot produced by Snapshot and inserts its entries,
+// preserving remaining TTL, cost and approximate recency order. Existing
+// entries with the same keys are overwritten; others are kept. The whole
+// input is validated (header, records, checksum) before anything is applied,
+// so a corrupt or incompatible snapshot leaves the cache unchanged.
+func (c *cache[K, V]) Restore(r io.Reader) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	kc, vc := c.codecs()
+	cr := &crcReader{r: bufio.NewReader(r), h: crc32.New(crcTable)}
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(cr, magic); err != nil || string(magic) != snapshotMagic {
+		return fmt.Errorf("%w: bad magic", ErrSnapshotCorrupt)
+	}
+	var version uint16
+	if err := binary.Read(cr, binary.LittleEndian, &version); err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	kname, err := cr.readBytes()
+	if err != nil {
+		return err
+	}
+	vname, err := cr.readBytes()
+	if err != nil {
+		return err
+	}
+	if string(kname) != kc.Name() || string(vname) != vc.Name() {
+		return fmt.Errorf("%w: file %s/%s, cache %s/%s",
+			ErrSnapshotCodec, kname, vname, kc.Name(), vc.Name())
+	}
+
+	var entries []snapshotEntry[K, V]
+	for {
+		tag, err := cr.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+		}
+		if tag == endTag {
+			break
+		}
+		if tag != recordTag {
+			return fmt.Errorf("%w: unknown record tag %#x", ErrSnapshotCorrupt, tag)
+		}
+		e, err := readEntry(cr, kc, vc)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+	count, err := cr.readUvarint()
+	if err != nil {
+		return err
+	}
+	sum := cr.h.Sum32()
+	var want uint32
+	if err := binary.Read(cr.r, binary.LittleEndian, &want); err != nil {
+		return fmt.Errorf("%w: missing checksum", ErrSnapshotCorrupt)
+	}
+	if sum != want || count != uint64(len(entries)) {
+		return fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+
+	for _, e := range entries {
+		c.getShard(e.key).Set(e.key, e.val, c.deadline(time.Duration(e.ttl)), e.idle, e.cost)
+		c.enforceBudget()
+	}
+	return nil
+}
+
+// readEntry decodes one record body.
+func readEntry[K comparable, V any](cr *crcReader, kc Codec[K], vc Codec[V]) (e snapshotEntry[K, V], err error) {
+	kb, err := cr.readBytes()
+	if err != nil {
+		return e, err
+	}
+	vb, err := cr.readBytes()
+	if err != nil {
+		return e, err
+	}
+	ttl, err := cr.readUvarint()
+	if err != nil {
+		return e, err
+	}
+	cost, err := cr.readUvarint()
+	if err != nil {
+		return e, err
+	}
+	idle, err := cr.readUvarint()
+	if err != nil {
+		return e, err
+	}
+	if ttl > math.MaxInt64 || cost > math.MaxInt32 || idle > math.MaxInt64 {
+		return e, fmt.Errorf("%w: field out of range", ErrSnapshotCorrupt)
+	}
+	if e.key, err = kc.Unmarshal(kb); err != nil {
+		return e, fmt.Errorf("%w: key: %v", ErrSnapshotCorrupt, err)
+	}
+	if e.val, err = vc.Unmarshal(vb); err != nil {
+		return e, fmt.Errorf("%w: value: %v", ErrSnapshotCorrupt, err)
+	}
+	e.ttl, e.idle, e.cost = int64(ttl), int64(idle), int32(cost)
+	return e, nil
+}
+
+// codecs returns the configured codecs, defaulting to gob.
+func (c *cache[K, V]) codecs() (Codec[K], Codec[V]) {
+	var kc Codec[K] = GobCodec[K]{}
+	var vc Codec[V] = GobCodec[V]{}
+	if c.opt.KeyCodec != nil {
+		kc = c.opt.KeyCodec
+	}
+	if c.opt.ValueCodec != nil {
+		vc = c.opt.ValueCodec
+	}
+	return kc, vc
+}
+
+// snapshot copies the live entries of the shard from LRU to MRU,
+// with TTLs converted to remaining durations.
+func (s *shard[K, V]) snapshot() []snapshotEntry[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainReadsLocked()
+
+	now := s.now()
+	out := make([]snapshotEntry[K, V], 0, s.len)
+	for n := s.tail; n != nil; n = n.prev {
+		e := snapshotEntry[K, V]{key: n.key, val: n.val, idle: n.idle, cost: n.cost}
+		if exp := n.deadline(); exp != 0 {
+			if now >= exp {
+				continue // expired (possibly retained for SWR)
+			}
+			e.ttl = exp - now
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// crcReader hashes every byte it hands out.
+type crcReader struct {
+	r *bufio.Reader
+	h hash.Hash32
+}
+
+func (cr *crcReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.h.Write(p[:n])
+	return n, err
+}
+
+func (cr *crcReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (cr *crcReader) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return x, nil
+}
+
+func (cr *crcReader) readBytes() ([]byte, error) {
+	n, err := cr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotBlob {
+		return nil, fmt.Errorf("%w: length %d too large", ErrSnapshotCorrupt, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(cr, b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return b, nil
+}
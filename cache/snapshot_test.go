@@ -0,0 +1,196 @@
+package shardcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Snapshot → Restore round-trips values, remaining TTL, cost and recency.
+func TestSnapshot_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		kc   Codec[string]
+		vc   Codec[[]int]
+	}{
+		{"gob", nil, nil},
+		{"json", JSONCodec[string]{}, JSONCodec[[]int]{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := &fakeClock{}
+			opt := Options[string, []int]{
+				Capacity:   3,
+				Shards:     1,
+				Clock:      clk,
+				Cost:       func(v []int) int { return len(v) },
+				KeyCodec:   tc.kc,
+				ValueCodec: tc.vc,
+			}
+			src := New[string, []int](opt)
+			t.Cleanup(func() { _ = src.Close() })
+
+			src.Set("a", []int{1})
+			src.SetWithTTL("b", []int{2, 2}, time.Second)
+			src.Set("c", []int{3, 3, 3})
+			src.Get("a") // recency: a is MRU, b is LRU
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			clk.add(400 * time.Millisecond) // restore "later"
+			dst := New[string, []int](opt)
+			t.Cleanup(func() { _ = dst.Close() })
+			if err := dst.Restore(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if got := dst.Stats(); got.Len != 3 || got.Cost != 6 {
+				t.Fatalf("restored len=%d cost=%d, want 3/6", got.Len, got.Cost)
+			}
+			if v, ok := dst.Get("c"); !ok || len(v) != 3 || v[2] != 3 {
+				t.Fatalf("c: got %v ok=%v", v, ok)
+			}
+
+			// Recency: inserting a 4th key evicts the restored LRU (b).
+			dst.Set("d", []int{4})
+			if _, ok := dst.Get("b"); ok {
+				t.Fatal("b was LRU in the snapshot and must be evicted first")
+			}
+
+			// Remaining TTL (600ms at the second snapshot) is counted from
+			// the restore time.
+			dst2 := New[string, []int](opt)
+			t.Cleanup(func() { _ = dst2.Close() })
+			buf.Reset()
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if err := dst2.Restore(&buf); err != nil {
+				t.Fatal(err)
+			}
+			clk.add(500 * time.Millisecond)
+			if _, ok := dst2.Get("b"); !ok {
+				t.Fatal("b must still be fresh 500ms after restore")
+			}
+			clk.add(200 * time.Millisecond)
+			if _, ok := dst2.Get("b"); ok {
+				t.Fatal("b must expire after its remaining TTL")
+			}
+		})
+	}
+}
+
+// Damaged or incompatible snapshots fail cleanly and leave the cache untouched.
+func TestSnapshot_Errors(t *testing.T) {
+	t.Parallel()
+
+	src := New[string, int](Options[string, int]{Capacity: 8})
+	t.Cleanup(func() { _ = src.Close() })
+	src.Set("a", 1)
+	src.Set("b", 2)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	flip := append([]byte(nil), good...)
+	flip[len(flip)-6] ^= 0xff // inside the record stream
+
+	version := append([]byte(nil), good...)
+	version[4] = 99
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrSnapshotCorrupt},
+		{"truncated", good[:len(good)-3], ErrSnapshotCorrupt},
+		{"bitflip", flip, ErrSnapshotCorrupt},
+		{"version", version, ErrSnapshotVersion},
+	} {
+		dst := New[string, int](Options[string, int]{Capacity: 8})
+		if err := dst.Restore(bytes.NewReader(tc.data)); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: want %v, got %v", tc.name, tc.want, err)
+		}
+		if dst.Len() != 0 {
+			t.Fatalf("%s: failed restore must not insert entries", tc.name)
+		}
+		_ = dst.Close()
+	}
+
+	dst := New[string, int](Options[string, int]{Capacity: 8, ValueCodec: JSONCodec[int]{}})
+	t.Cleanup(func() { _ = dst.Close() })
+	if err := dst.Restore(bytes.NewReader(good)); !errors.Is(err, ErrSnapshotCodec) {
+		t.Fatalf("codec mismatch: got %v", err)
+	}
+}
+
+// Sliding-TTL entries keep their idle window across Snapshot/Restore.
+func TestSnapshot_IdleTTL(t *testing.T) {
+	t.Parallel()
+
+	clk := &fakeClock{t: 1}
+	opt := Options[string, int]{Capacity: 4, Shards: 1, Clock: clk}
+	src := New[string, int](opt)
+	t.Cleanup(func() { _ = src.Close() })
+	src.SetWithIdleTTL("s", 1, time.Second)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := New[string, int](opt)
+	t.Cleanup(func() { _ = dst.Close() })
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, ok := dst.Inspect("s"); !ok || info.Idle != time.Second {
+		t.Fatalf("want idle 1s after restore, got %+v ok=%v", info, ok)
+	}
+	// Reads keep extending the deadline well past the original second.
+	for i := 0; i < 4; i++ {
+		clk.add(600 * time.Millisecond)
+		if _, ok := dst.Get("s"); !ok {
+			t.Fatalf("restored sliding entry expired after %d reads", i)
+		}
+	}
+}
+
+// Records are interleaved across shards by recency, so restoring into a
+// smaller cache that shards keys differently keeps the hottest entries.
+func TestSnapshot_RecencyAcrossShards(t *testing.T) {
+	t.Parallel()
+
+	src := New[int, int](Options[int, int]{
+		Capacity: 64,
+		Shards:   4,
+		Hasher:   func(k int) uint64 { return uint64(k) }, // shard = k mod 4
+	})
+	t.Cleanup(func() { _ = src.Close() })
+	for k := 0; k < 40; k++ {
+		src.Set(k, k)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := New[int, int](Options[int, int]{Capacity: 8, Shards: 1})
+	t.Cleanup(func() { _ = dst.Close() })
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for k := 32; k < 40; k++ {
+		if !dst.Contains(k) {
+			t.Fatalf("want the 8 most recent keys 32..39 after restore, missing %d", k)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package shardcache
+
+import "time"
+
+// evictReasonCount is the number of distinct EvictReason values.
+const evictReasonCount = int(EvictClosed) + 1
+
+// ShardStats is a point-in-time view of one shard's counters.
+// Counters are cumulative since New; Len and Cost are current values.
+type ShardStats struct {
+	Len  int   // resident entries
+	Cost int64 // resident cost
+
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts evictions by reason (e.g. Evictions[EvictTTL]);
+	// reasons that never occurred are absent (read as 0).
+	Evictions map[EvictReason]uint64
+	// Removes counts explicit Remove calls that deleted an entry.
+	Removes uint64
+
+	Loads      uint64        // Loader calls
+	LoadErrors uint64        // Loader calls that returned an error
+	LoadTime   time.Duration // total time spent in Loader
+
+	Refreshes     uint64 // reloads of cached entries (included in Loads)
+	RefreshErrors uint64 // failed reloads; the old value was kept
+}
+
+// Stats is a point-in-time snapshot of cache counters: the embedded
+// ShardStats holds totals across all shards, Shards the per-shard breakdown.
+//
+// Shards are read one at a time, so the totals are not an atomic snapshot of
+// the whole cache under concurrent writes.
+type Stats struct {
+	ShardStats
+	Shards []ShardStats
+}
+
+// TotalEvictions returns the sum of evictions over all reasons.
+func (s ShardStats) TotalEvictions() uint64 {
+	var total uint64
+	for _, n := range s.Evictions {
+		total += n
+	}
+	return total
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 if there were no lookups.
+func (s ShardStats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(lookups)
+}
+
+// AvgLoadTime returns the mean Loader latency, or 0 if nothing was loaded.
+func (s ShardStats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// add accumulates o into s.
+func (s *ShardStats) add(o ShardStats) {
+	s.Len += o.Len
+	s.Cost += o.Cost
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	for r, n := range o.Evictions {
+		if s.Evictions == nil {
+			s.Evictions = make(map[EvictReason]uint64, len(o.Evictions))
+		}
+		s.Evictions[r] += n
+	}
+	s.Removes += o.Removes
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.LoadTime += o.LoadTime
+	s.Refreshes += o.Refreshes
+	s.RefreshErrors += o.RefreshErrors
+}
+
+// stats returns a snapshot of this shard's counters.
+func (s *shard[K, V]) stats() ShardStats {
+	s.mu.RLock()
+	st := ShardStats{Len: s.len, Cost: s.cost}
+	s.mu.RUnlock()
+
+	st.Hits = uint64(s.hits.Load())
+	st.Misses = uint64(s.misses.Load())
+	for i := range s.evicts {
+		if n := s.evicts[i].Load(); n > 0 {
+			if st.Evictions == nil {
+				st.Evictions = make(map[EvictReason]uint64, evictReasonCount)
+			}
+			st.Evictions[EvictReason(i)] = n
+		}
+	}
+	st.Removes = s.removes.Load()
+	st.Loads = s.loads.Load()
+	st.LoadErrors = s.loadErrs.Load()
+	st.LoadTime = time.Duration(s.loadNanos.Load())
+	st.Refreshes = s.refreshes.Load()
+	st.RefreshErrors = s.refreshErrs.Load()
+	return st
+}
+
+// recordLoad accounts a finished Loader call.
+func (s *shard[K, V]) recordLoad(d time.Duration, err error) {
+	s.loads.Add(1)
+	s.loadNanos.Add(int64(d))
+	if err != nil {
+		s.loadErrs.Add(1)
+	}
+}
+
+// recordRefresh accounts a finished reload of a cached entry.
+func (s *shard[K, V]) recordRefresh(err error) {
+	s.refreshes.Add(1)
+	if err != nil {
+		s.refreshErrs.Add(1)
+	}
+	s.refresh.Refresh(err == nil)
+}
@@ -16,7 +16,9 @@ import (
 	"time"
 
 	"github.com/IvanBrykalov/shardcache/cache"
+	"github.com/IvanBrykalov/shardcache/internal/util"
 	pmet "github.com/IvanBrykalov/shardcache/metrics/prom"
+	"github.com/IvanBrykalov/shardcache/policy/tinylfu"
 	"github.com/IvanBrykalov/shardcache/policy/twoq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -26,7 +28,8 @@ func main() {
 	var (
 		capacity = flag.Int("cap", 100_000, "cache capacity (entries)")
 		shards   = flag.Int("shards", 0, "number of shards (0=auto)")
-		policy   = flag.String("policy", "lru", "eviction policy: lru | 2q")
+		policy   = flag.String("policy", "lru", "eviction policy: lru | 2q | tinylfu")
+		readBuf  = flag.Bool("readbuf", true, "buffer hit promotions (false = write lock on every Get)")
 
 		workers  = flag.Int("workers", 2*runtime.GOMAXPROCS(0), "number of worker goroutines")
 		duration = flag.Duration("duration", 10*time.Second, "benchmark duration")
@@ -61,9 +64,10 @@ func main() {
 
 	// ---- Build cache ----
 	opt := shardcache.Options[string, string]{
-		Capacity: *capacity,
-		Shards:   *shards,
-		Metrics:  metrics,
+		Capacity:          *capacity,
+		Shards:            *shards,
+		Metrics:           metrics,
+		DisableReadBuffer: !*readBuf,
 	}
 	switch *policy {
 	case "lru":
@@ -71,8 +75,16 @@ func main() {
 	case "2q":
 		// split 2Q queues as a simple default
 		opt.Policy = twoq.New[string, string](*capacity/4, *capacity/2)
+	case "tinylfu":
+		// W-TinyLFU is sized per shard; round the count up like New does
+		sh := *shards
+		if sh <= 0 {
+			sh = 2 * runtime.GOMAXPROCS(0)
+		}
+		sh = int(util.NextPow2(uint64(sh)))
+		opt.Policy = tinylfu.New[string, string]((*capacity + sh - 1) / sh)
 	default:
-		log.Fatalf("unknown policy: %q (use lru or 2q)", *policy)
+		log.Fatalf("unknown policy: %q (use lru, 2q or tinylfu)", *policy)
 	}
 	c := shardcache.New[string, string](opt)
 	defer func() { _ = c.Close() }()
@@ -156,8 +168,8 @@ func main() {
 		hitRate = float64(hitsN) / float64(readsN) * 100
 	}
 
-	fmt.Printf("policy=%s cap=%d shards=%d workers=%d keys=%d dur=%v seed=%d\n",
-		*policy, *capacity, *shards, workersN, *keys, elapsed, seedBase)
+	fmt.Printf("policy=%s readbuf=%v cap=%d shards=%d workers=%d keys=%d dur=%v seed=%d\n",
+		*policy, *readBuf, *capacity, *shards, workersN, *keys, elapsed, seedBase)
 	fmt.Printf("ops=%d (%.0f ops/s)  reads=%d  writes=%d\n",
 		ops, float64(ops)/elapsed.Seconds(), readsN, writesN)
 	fmt.Printf("hits=%d  misses=%d  hit-rate=%.2f%%\n", hitsN, missesN, hitRate)
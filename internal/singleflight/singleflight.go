@@ -3,6 +3,9 @@ package singleflight
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
 )
 
@@ -11,65 +14,234 @@ import (
 // wait for the shared result.
 //
 // Concurrency notes:
-//   - The first caller for a given key becomes the leader and runs fn.
-//   - Followers wait on c.done. Publishing (val, err) happens-before
-//     close(c.done), so reads after <-done observe the final values.
-//   - Cancelling ctx in a follower unblocks only that follower; it does
-//     NOT cancel the leader's fn. If you need cancellation of the work,
-//     pass ctx into fn and handle it there.
+//   - The first caller for a given key starts fn in its own goroutine; it and
+//     every later caller for the key are waiters of the call.
+//   - fn runs under a context detached from any single caller: it keeps the
+//     first caller's values (trace IDs etc.) but not its cancellation or
+//     deadline. A waiter whose ctx is done stops waiting and gets ctx.Err();
+//     when the last waiter leaves, fn's context is cancelled and the key is
+//     released, so the next caller starts a fresh call.
+//   - Publishing (val, err) happens-before close(c.done), so reads after
+//     <-done observe the final values.
+//   - A panic in fn is recovered and delivered to all waiters as a
+//     *PanicError.
 type Group[K comparable, V any] struct {
 	mu sync.Mutex
-	m  map[K]*call[V]
+	m  map[K]*Call[V]
 }
 
-type call[V any] struct {
+// Call is an in-flight (or finished) execution registered for a key.
+type Call[V any] struct {
 	done chan struct{} // closed when val/err are published
 	val  V
 	err  error
+
+	// waiters counts callers blocked in Wait; cancel stops fn once it drops
+	// to zero (nil for calls registered by Claim). Both are guarded by the
+	// owning group's mu.
+	waiters int
+	cancel  context.CancelFunc
+	leave   func() // called by a waiter that gives up
 }
 
-// Do runs fn once for the given key. Concurrent calls with the same key
-// wait for the shared result. If ctx is cancelled in a follower, that
-// follower returns ctx.Err() while the leader continues to run fn.
-//
-// Important:
-//   - ctx cancellation does not stop the leader's fn. If cancellation of
-//     the underlying work is required, thread ctx into fn and handle it there.
-func (g *Group[K, V]) Do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
-	// Fast path: an in-flight call exists — wait (respecting ctx).
+// ErrPanic is wrapped by every *PanicError.
+var ErrPanic = errors.New("singleflight: call panicked")
+
+// PanicError is the error returned to every waiter when fn panics.
+type PanicError struct {
+	Value any    // the value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v\n\n%s", ErrPanic, e.Value, e.Stack)
+}
+
+// Unwrap returns ErrPanic.
+func (e *PanicError) Unwrap() error { return ErrPanic }
+
+// Wait blocks until the call is resolved or ctx is done, whichever comes
+// first. A waiter that gives up no longer holds the call open: once no
+// waiters are left, a call started by Do is cancelled.
+func (c *Call[V]) Wait(ctx context.Context) (V, error) {
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		if c.leave != nil {
+			c.leave()
+		}
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Do runs fn once for the given key and waits for its result. Concurrent
+// calls with the same key join the call in flight. fn receives a context
+// detached from ctx (values preserved) that is cancelled only when every
+// caller waiting for the key has given up.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	return g.start(ctx, key, fn).Wait(ctx)
+}
+
+// Result is the outcome of a call delivered by DoChan.
+type Result[V any] struct {
+	Val V
+	Err error
+}
+
+// DoChan is like Do but does not block: the returned channel receives one
+// Result and is then closed. If ctx is done before the call finishes, the
+// Result carries ctx.Err() and the caller stops counting as a waiter.
+func (g *Group[K, V]) DoChan(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) <-chan Result[V] {
+	c := g.start(ctx, key, fn)
+	ch := make(chan Result[V], 1)
+	go func() {
+		v, err := c.Wait(ctx)
+		ch <- Result[V]{Val: v, Err: err}
+		close(ch)
+	}()
+	return ch
+}
+
+// start joins the call in flight for key (counting the caller as a waiter)
+// or registers a new one and runs fn for it in a new goroutine.
+func (g *Group[K, V]) start(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) *Call[V] {
 	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.m == nil {
-		g.m = make(map[K]*call[V])
+		g.m = make(map[K]*Call[V])
 	}
 	if c, ok := g.m[key]; ok {
-		done := c.done
-		g.mu.Unlock()
+		c.waiters++
+		return c
+	}
+
+	fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := g.newCallLocked(key)
+	c.waiters = 1
+	c.cancel = cancel
+	go g.run(fctx, key, c, fn)
+	return c
+}
 
-		select {
-		case <-done:
-			return c.val, c.err
-		case <-ctx.Done():
+// run executes fn for c and resolves it, converting a panic into a
+// *PanicError so that waiters are never left blocked.
+func (g *Group[K, V]) run(ctx context.Context, key K, c *Call[V], fn func(ctx context.Context) (V, error)) {
+	var (
+		v   V
+		err error
+	)
+	defer func() {
+		if r := recover(); r != nil {
 			var zero V
-			return zero, ctx.Err()
+			v, err = zero, &PanicError{Value: r, Stack: debug.Stack()}
 		}
-	}
+		c.cancel()
+		g.Resolve(key, c, v, err)
+	}()
+	v, err = fn(ctx)
+}
 
-	// We are the leader for this key.
-	c := &call[V]{done: make(chan struct{})}
+// newCallLocked registers a new in-flight call for key. g.mu must be held.
+func (g *Group[K, V]) newCallLocked(key K) *Call[V] {
+	c := &Call[V]{done: make(chan struct{})}
+	c.leave = func() { g.leave(key, c) }
 	g.m[key] = c
-	g.mu.Unlock()
+	return c
+}
+
+// leave drops a waiter of c. When the last waiter of a cancellable call
+// leaves before it finished, the call is cancelled and key is released.
+func (g *Group[K, V]) leave(key K, c *Call[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c.waiters > 0 {
+		c.waiters--
+	}
+	if c.waiters > 0 || c.cancel == nil {
+		return
+	}
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.cancel()
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+}
+
+// Claim registers a new in-flight call for every key that has none and
+// returns those as owned; keys with a call already in flight are returned
+// as joined. The caller counts as a waiter of both. It must Resolve every
+// owned call exactly once (also on failure), otherwise their waiters block
+// until their ctx is done. The owned calls are meant to be loaded together
+// under bctx, which is detached from ctx (values preserved) like the context
+// of a Do call: it is cancelled once every owned call has lost all of its
+// waiters, the caller included. This lets a batch of keys be loaded together
+// while still coalescing with single-key Do calls.
+func (g *Group[K, V]) Claim(ctx context.Context, keys []K) (bctx context.Context, owned, joined map[K]*Call[V]) {
+	owned = make(map[K]*Call[V], len(keys))
+	joined = make(map[K]*Call[V])
+	b := &batch{}
+	bctx, b.cancel = context.WithCancel(context.WithoutCancel(ctx))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = make(map[K]*Call[V])
+	}
+	for _, k := range keys {
+		if c, ok := g.m[k]; ok {
+			c.waiters++
+			joined[k] = c
+			continue
+		}
+		c := g.newCallLocked(k)
+		c.waiters = 1
+		released := false
+		c.cancel = func() { // called by leave under g.mu
+			if !released {
+				released = true
+				b.release()
+			}
+		}
+		owned[k] = c
+		b.live++
+	}
+	if b.live == 0 {
+		b.cancel()
+	}
+	return bctx, owned, joined
+}
 
-	// Execute fn outside the lock.
-	v, err := fn()
+// batch is the context shared by the calls owned by one Claim.
+type batch struct {
+	cancel context.CancelFunc
+	live   int // owned calls that still have waiters; guarded by Group.mu
+}
+
+// release drops an owned call that lost its last waiter and cancels the
+// batch once none is left.
+func (b *batch) release() {
+	if b.live--; b.live == 0 {
+		b.cancel()
+	}
+}
 
+// Resolve publishes the result of call c for key, wakes its waiters and
+// removes the in-flight marker.
+func (g *Group[K, V]) Resolve(key K, c *Call[V], v V, err error) {
 	// Publish result and wake followers.
 	c.val, c.err = v, err
 	close(c.done)
 
 	// Remove the in-flight marker.
 	g.mu.Lock()
-	delete(g.m, key)
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
 	g.mu.Unlock()
-
-	return v, err
 }
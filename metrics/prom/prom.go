@@ -9,11 +9,13 @@ import (
 // Adapter implements cache.Metrics and exports Prometheus counters/gauges.
 // Safe for concurrent use; all Prometheus metric types are goroutine-safe.
 type Adapter struct {
-	hits     prometheus.Counter
-	misses   prometheus.Counter
-	evicts   *prometheus.CounterVec
-	sizeEnt  prometheus.Gauge
-	sizeCost prometheus.Gauge
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evicts    *prometheus.CounterVec
+	refreshes *prometheus.CounterVec
+	circuit   prometheus.Gauge
+	sizeEnt   prometheus.Gauge
+	sizeCost  prometheus.Gauge
 }
 
 // New constructs a Prometheus metrics adapter.
@@ -49,6 +51,23 @@ func New(reg prometheus.Registerer, ns, sub string, constLabels prometheus.Label
 			},
 			[]string{"reason"},
 		),
+		refreshes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   ns,
+				Subsystem:   sub,
+				Name:        "refreshes_total",
+				Help:        "Reloads of cached entries by result",
+				ConstLabels: constLabels,
+			},
+			[]string{"result"},
+		),
+		circuit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   sub,
+			Name:        "loader_circuit_state",
+			Help:        "Loader circuit breaker state (0=closed, 1=open, 2=half-open)",
+			ConstLabels: constLabels,
+		}),
 		sizeEnt: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace:   ns,
 			Subsystem:   sub,
@@ -64,7 +83,7 @@ func New(reg prometheus.Registerer, ns, sub string, constLabels prometheus.Label
 			ConstLabels: constLabels,
 		}),
 	}
-	reg.MustRegister(a.hits, a.misses, a.evicts, a.sizeEnt, a.sizeCost)
+	reg.MustRegister(a.hits, a.misses, a.evicts, a.refreshes, a.circuit, a.sizeEnt, a.sizeCost)
 	return a
 }
 
@@ -85,6 +104,18 @@ func (a *Adapter) Size(entries int, cost int64) {
 	a.sizeCost.Set(float64(cost))
 }
 
+// Refresh increments the refresh counter with a result label ("ok"/"error").
+func (a *Adapter) Refresh(ok bool) {
+	result := "error"
+	if ok {
+		result = "ok"
+	}
+	a.refreshes.WithLabelValues(result).Inc()
+}
+
+// Circuit sets the breaker state gauge.
+func (a *Adapter) Circuit(st shardcache.CircuitState) { a.circuit.Set(float64(st)) }
+
 // reason maps EvictReason to a stable label value.
 func reason(r shardcache.EvictReason) string {
 	switch r {
@@ -92,10 +123,17 @@ func reason(r shardcache.EvictReason) string {
 		return "ttl"
 	case shardcache.EvictCapacity:
 		return "capacity"
+	case shardcache.EvictClosed:
+		return "closed"
 	default:
 		return "policy"
 	}
 }
 
-// Compile-time check: ensure Adapter implements cache.Metrics.
-var _ shardcache.Metrics = (*Adapter)(nil)
+// Compile-time checks: ensure Adapter implements cache.Metrics and its
+// optional extensions.
+var (
+	_ shardcache.Metrics        = (*Adapter)(nil)
+	_ shardcache.RefreshMetrics = (*Adapter)(nil)
+	_ shardcache.CircuitMetrics = (*Adapter)(nil)
+)
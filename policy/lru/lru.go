@@ -20,8 +20,8 @@ func (lruPolicy[K, V]) New(h policy.Hooks[K, V]) policy.ShardPolicy[K, V] {
 	return &lru[K, V]{h: h}
 }
 
-// OnAdd places the new entry at MRU. LRU itself doesn't choose evictions;
-// the shard enforces capacity/cost limits and performs actual evictions.
+// OnAdd places the new entry at MRU. LRU never evicts on admission;
+// the shard asks Victim when capacity/cost limits are exceeded.
 func (p *lru[K, V]) OnAdd(n policy.Node[K, V]) (evict policy.Node[K, V]) {
 	p.h.PushFront(n)
 	return nil
@@ -35,3 +35,6 @@ func (p *lru[K, V]) OnUpdate(n policy.Node[K, V]) { p.h.MoveToFront(n) }
 
 // OnRemove is a no-op for pure LRU (nothing to clean up in policy state).
 func (p *lru[K, V]) OnRemove(_ policy.Node[K, V]) {}
+
+// Victim returns the least recently used entry (the shard list tail).
+func (p *lru[K, V]) Victim() policy.Node[K, V] { return p.h.Back() }
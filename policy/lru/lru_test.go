@@ -108,3 +108,16 @@ func TestLRU_OnRemove_NoOp(t *testing.T) {
 		t.Fatalf("OnRemove for LRU must be no-op (no hooks should be called)")
 	}
 }
+
+// Victim returns the shard list tail (LRU).
+func TestLRU_Victim_Back(t *testing.T) {
+	t.Parallel()
+
+	n := &testNode[string, int]{k: "k5", v: 5}
+	h := &mockHooks[string, int]{backVal: n}
+	p := New[string, int]().New(h)
+
+	if v := p.Victim(); v != n {
+		t.Fatalf("Victim must return Back(), got %v", v)
+	}
+}
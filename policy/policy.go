@@ -36,11 +36,23 @@ type Hooks[K comparable, V any] interface {
 //   - OnGet/OnUpdate typically promote the node (e.g., move to MRU).
 //   - OnRemove is a notification to update policy-internal state
 //     (e.g., maintain ghost queues). The shard performs actual deletion.
+//   - Victim is consulted whenever the shard exceeds its entry or cost limit;
+//     it returns the resident node to evict next (nil if none). It must not
+//     mutate policy state: the shard evicts the node and calls OnRemove.
 type ShardPolicy[K comparable, V any] interface {
 	OnAdd(Node[K, V]) (evict Node[K, V])
 	OnGet(Node[K, V])
 	OnUpdate(Node[K, V])
 	OnRemove(Node[K, V])
+	Victim() Node[K, V]
+}
+
+// Segmenter is an optional interface of a ShardPolicy that keeps resident
+// nodes in distinct segments (e.g. 2Q A1in/Am). Segment names the segment
+// holding n ("" if n is not tracked). It is used for inspection only and must
+// not mutate policy state; it may be called under the shard read lock.
+type Segmenter[K comparable, V any] interface {
+	Segment(Node[K, V]) string
 }
 
 // Policy is a factory that creates shard-local policy instances
@@ -0,0 +1,124 @@
+package tinylfu
+
+import "github.com/IvanBrykalov/shardcache/internal/util"
+
+// sketch is a count-min sketch with 4 rows of saturating 4-bit counters
+// (stored one per byte for simplicity) and a doorkeeper bloom filter in front
+// of it. It estimates access frequency within a sliding sample: after
+// sampleSize recorded accesses all counters are halved and the doorkeeper is
+// cleared (aging), so past popularity decays.
+type sketch struct {
+	rows  [sketchDepth][]uint8
+	mask  uint64
+	door  []uint64 // doorkeeper bitset
+	dmask uint64
+
+	additions  int
+	sampleSize int
+}
+
+const (
+	sketchDepth = 4
+	counterMax  = 15 // 4-bit saturation
+)
+
+// newSketch sizes the sketch for roughly capacity distinct hot keys.
+func newSketch(capacity int) *sketch {
+	width := util.NextPow2(uint64(capacity))
+	if width < 16 {
+		width = 16
+	}
+	s := &sketch{
+		mask:       width - 1,
+		door:       make([]uint64, width*4/64), // ~4 bits per key
+		dmask:      width*4 - 1,
+		sampleSize: 10 * capacity,
+	}
+	if s.sampleSize < 16 {
+		s.sampleSize = 16
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// increment records one access for the key hash h. The first occurrence of a
+// key within a sample only sets the doorkeeper; later ones bump the counters.
+func (s *sketch) increment(h uint64) {
+	if !s.doorSet(h) {
+		s.additions++
+	} else {
+		added := false
+		for i := range s.rows {
+			idx := s.index(h, i)
+			if s.rows[i][idx] < counterMax {
+				s.rows[i][idx]++
+				added = true
+			}
+		}
+		if added {
+			s.additions++
+		}
+	}
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// estimate returns the approximate access frequency of the key hash h.
+func (s *sketch) estimate(h uint64) int {
+	min := uint8(counterMax)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	f := int(min)
+	if s.doorHas(h) {
+		f++
+	}
+	return f
+}
+
+// reset halves all counters and clears the doorkeeper.
+func (s *sketch) reset() {
+	for i := range s.rows {
+		row := s.rows[i]
+		for j := range row {
+			row[j] >>= 1
+		}
+	}
+	clear(s.door)
+	s.additions /= 2
+}
+
+// index derives the counter position for row i via double hashing.
+func (s *sketch) index(h uint64, i int) uint64 {
+	h1, h2 := h, (h>>32)|1
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+// doorSet sets the doorkeeper bits for h and reports whether they were
+// all already set (i.e. the key was seen before in this sample).
+func (s *sketch) doorSet(h uint64) bool {
+	seen := true
+	for _, b := range [2]uint64{h & s.dmask, (h >> 32) & s.dmask} {
+		w, bit := b/64, uint64(1)<<(b%64)
+		if s.door[w]&bit == 0 {
+			seen = false
+			s.door[w] |= bit
+		}
+	}
+	return seen
+}
+
+// doorHas reports whether h is present in the doorkeeper.
+func (s *sketch) doorHas(h uint64) bool {
+	for _, b := range [2]uint64{h & s.dmask, (h >> 32) & s.dmask} {
+		if s.door[b/64]&(uint64(1)<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
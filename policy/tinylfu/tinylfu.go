@@ -0,0 +1,231 @@
+// Package tinylfu implements the Window-TinyLFU (W-TinyLFU) eviction policy.
+package tinylfu
+
+import (
+	"container/list"
+	"hash/maphash"
+
+	"github.com/IvanBrykalov/shardcache/policy"
+)
+
+// segment identifies the queue a resident node belongs to.
+type segment uint8
+
+const (
+	segWindow    segment = iota // admission window (LRU)
+	segProbation                // main SLRU, probationary part
+	segProtected                // main SLRU, protected part
+)
+
+func (s segment) String() string {
+	switch s {
+	case segWindow:
+		return "window"
+	case segProbation:
+		return "probation"
+	default:
+		return "protected"
+	}
+}
+
+// entry is the list element payload for a resident node.
+type entry[K comparable, V any] struct {
+	n   policy.Node[K, V]
+	seg segment
+}
+
+// tinyLFU implements W-TinyLFU as described by Einziger et al. and used by
+// Caffeine:
+//
+//   - Window  — a small LRU (≈1% of capacity) that admits every new entry,
+//     so recency bursts are not rejected outright.
+//   - Main    — a segmented LRU: probation (≈20%) and protected (≈80%).
+//     A hit in probation promotes the entry to protected; protected overflow
+//     is demoted back to probation.
+//   - Filter  — a count-min sketch with a doorkeeper and periodic aging.
+//     When the window overflows, its LRU (the candidate) competes with the
+//     probation LRU (the victim); the one with the lower estimated frequency
+//     is evicted. This keeps one-hit wonders and scans out of the main area.
+//
+// The shard list is kept in global recency order via hooks (MRU on every
+// access); the policy's own lists decide evictions.
+//
+// Concurrency: all methods are called under the shard lock.
+type tinyLFU[K comparable, V any] struct {
+	h policy.Hooks[K, V]
+
+	capWindow    int
+	capMain      int
+	capProtected int
+
+	window    *list.List // MRU at Front()
+	probation *list.List
+	protected *list.List
+	idx       map[policy.Node[K, V]]*list.Element // element.Value is *entry
+
+	sketch *sketch
+	seed   maphash.Seed
+}
+
+// New constructs a W-TinyLFU policy factory for a shard holding up to
+// capacity entries. NOTE: When used with a sharded cache, pass the
+// *per-shard* capacity (Capacity / Shards, rounded up).
+func New[K comparable, V any](capacity int) policy.Policy[K, V] {
+	if capacity < 2 {
+		capacity = 2
+	}
+	return tinyLFUPolicy[K, V]{capacity: capacity}
+}
+
+type tinyLFUPolicy[K comparable, V any] struct {
+	capacity int
+}
+
+func (p tinyLFUPolicy[K, V]) New(h policy.Hooks[K, V]) policy.ShardPolicy[K, V] {
+	capWindow := p.capacity / 100
+	if capWindow < 1 {
+		capWindow = 1
+	}
+	capMain := p.capacity - capWindow
+	capProtected := capMain * 8 / 10
+	if capProtected < 1 {
+		capProtected = 1
+	}
+	return &tinyLFU[K, V]{
+		h:            h,
+		capWindow:    capWindow,
+		capMain:      capMain,
+		capProtected: capProtected,
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		idx:          make(map[policy.Node[K, V]]*list.Element),
+		sketch:       newSketch(p.capacity),
+		seed:         maphash.MakeSeed(),
+	}
+}
+
+// OnAdd records the access and admits n into the window. If the window
+// overflows, its LRU candidate either moves to probation (main has room or
+// the candidate is more frequent than the probation victim) or is rejected.
+// The loser of that comparison is returned for eviction.
+func (p *tinyLFU[K, V]) OnAdd(n policy.Node[K, V]) (evict policy.Node[K, V]) {
+	p.sketch.increment(p.hash(n))
+	p.h.PushFront(n)
+	p.idx[n] = p.window.PushFront(&entry[K, V]{n: n, seg: segWindow})
+
+	if p.window.Len() <= p.capWindow {
+		return nil
+	}
+	candEl := p.window.Back()
+	cand := candEl.Value.(*entry[K, V])
+
+	if p.probation.Len()+p.protected.Len() < p.capMain {
+		p.moveTo(candEl, segProbation)
+		return nil
+	}
+	victim := p.mainVictim()
+	if victim == nil {
+		p.moveTo(candEl, segProbation)
+		return nil
+	}
+	if p.sketch.estimate(p.hash(cand.n)) > p.sketch.estimate(p.hash(victim)) {
+		p.moveTo(candEl, segProbation)
+		return victim
+	}
+	return cand.n
+}
+
+// OnGet records the access and promotes n within its segment:
+// window → window MRU, probation → protected, protected → protected MRU.
+func (p *tinyLFU[K, V]) OnGet(n policy.Node[K, V]) {
+	p.sketch.increment(p.hash(n))
+	p.h.MoveToFront(n)
+
+	el, ok := p.idx[n]
+	if !ok {
+		return
+	}
+	e := el.Value.(*entry[K, V])
+	switch e.seg {
+	case segWindow:
+		p.window.MoveToFront(el)
+	case segProbation:
+		p.moveTo(el, segProtected)
+		// Demote protected overflow back to probation.
+		for p.protected.Len() > p.capProtected {
+			p.moveTo(p.protected.Back(), segProbation)
+		}
+	case segProtected:
+		p.protected.MoveToFront(el)
+	}
+}
+
+// OnUpdate follows OnGet semantics (updates count as recent use).
+func (p *tinyLFU[K, V]) OnUpdate(n policy.Node[K, V]) { p.OnGet(n) }
+
+// OnRemove drops n from whichever segment holds it. The sketch keeps its
+// frequency history so a returning key can win admission.
+func (p *tinyLFU[K, V]) OnRemove(n policy.Node[K, V]) {
+	if el, ok := p.idx[n]; ok {
+		p.listOf(el.Value.(*entry[K, V]).seg).Remove(el)
+		delete(p.idx, n)
+	}
+}
+
+// Victim returns the entry to evict when the shard exceeds its entry or cost
+// limit: the probation LRU, then the protected LRU, then the window LRU.
+// Admission already filtered the main area by frequency, so its coldest
+// entries go first and the window keeps absorbing new arrivals.
+func (p *tinyLFU[K, V]) Victim() policy.Node[K, V] {
+	if v := p.mainVictim(); v != nil {
+		return v
+	}
+	if el := p.window.Back(); el != nil {
+		return el.Value.(*entry[K, V]).n
+	}
+	return nil
+}
+
+// Segment reports the segment of n: "window", "probation" or "protected".
+func (p *tinyLFU[K, V]) Segment(n policy.Node[K, V]) string {
+	el, ok := p.idx[n]
+	if !ok {
+		return ""
+	}
+	return el.Value.(*entry[K, V]).seg.String()
+}
+
+// mainVictim returns the LRU of probation, falling back to protected.
+func (p *tinyLFU[K, V]) mainVictim() policy.Node[K, V] {
+	if el := p.probation.Back(); el != nil {
+		return el.Value.(*entry[K, V]).n
+	}
+	if el := p.protected.Back(); el != nil {
+		return el.Value.(*entry[K, V]).n
+	}
+	return nil
+}
+
+// moveTo relinks el at the MRU end of segment seg.
+func (p *tinyLFU[K, V]) moveTo(el *list.Element, seg segment) {
+	e := el.Value.(*entry[K, V])
+	p.listOf(e.seg).Remove(el)
+	e.seg = seg
+	p.idx[e.n] = p.listOf(seg).PushFront(e)
+}
+
+func (p *tinyLFU[K, V]) listOf(seg segment) *list.List {
+	switch seg {
+	case segWindow:
+		return p.window
+	case segProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+func (p *tinyLFU[K, V]) hash(n policy.Node[K, V]) uint64 {
+	return maphash.Comparable(p.seed, n.Key())
+}
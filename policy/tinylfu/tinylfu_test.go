@@ -0,0 +1,273 @@
+package tinylfu
+
+import (
+	"container/list"
+	"math/rand"
+	"testing"
+
+	"github.com/IvanBrykalov/shardcache/policy"
+)
+
+// --- test doubles (same shape as in LRU/2Q tests) ---
+
+type testNode[K comparable, V any] struct {
+	k K
+	v V
+}
+
+func (n *testNode[K, V]) Key() K    { return n.k }
+func (n *testNode[K, V]) Value() *V { return &n.v }
+
+type mockHooks[K comparable, V any] struct {
+	pushFrontCnt   int
+	moveToFrontCnt int
+}
+
+func (h *mockHooks[K, V]) MoveToFront(policy.Node[K, V]) { h.moveToFrontCnt++ }
+func (h *mockHooks[K, V]) PushFront(policy.Node[K, V])   { h.pushFrontCnt++ }
+func (h *mockHooks[K, V]) Remove(policy.Node[K, V])      {}
+func (h *mockHooks[K, V]) Back() policy.Node[K, V]       { return nil }
+func (h *mockHooks[K, V]) Len() int                      { return 0 }
+
+// sim drives a shard policy the way the shard does: evict candidates
+// returned by OnAdd are removed via OnRemove.
+type sim struct {
+	p     policy.ShardPolicy[int, int]
+	nodes map[int]*testNode[int, int]
+}
+
+func newSim(capacity int) *sim {
+	return &sim{
+		p:     New[int, int](capacity).New(&mockHooks[int, int]{}),
+		nodes: make(map[int]*testNode[int, int]),
+	}
+}
+
+func (s *sim) access(k int) bool {
+	if n, ok := s.nodes[k]; ok {
+		s.p.OnGet(n)
+		return true
+	}
+	n := &testNode[int, int]{k: k}
+	s.nodes[k] = n
+	if ev := s.p.OnAdd(n); ev != nil {
+		s.p.OnRemove(ev)
+		delete(s.nodes, ev.Key())
+	}
+	return false
+}
+
+func segOf(t *testing.T, p *tinyLFU[int, int], n policy.Node[int, int]) segment {
+	t.Helper()
+	el, ok := p.idx[n]
+	if !ok {
+		t.Fatalf("node %v is not resident", n.Key())
+	}
+	return el.Value.(*entry[int, int]).seg
+}
+
+// --- tests ---
+
+// A new entry is admitted into the window and placed at MRU via hooks.
+func TestTinyLFU_AddGoesToWindow(t *testing.T) {
+	t.Parallel()
+
+	h := &mockHooks[int, int]{}
+	p := New[int, int](100).New(h).(*tinyLFU[int, int])
+
+	n := &testNode[int, int]{k: 1}
+	if ev := p.OnAdd(n); ev != nil {
+		t.Fatalf("OnAdd must not evict, got %v", ev.Key())
+	}
+	if segOf(t, p, n) != segWindow {
+		t.Fatal("new entry must be in the window")
+	}
+	if h.pushFrontCnt != 1 {
+		t.Fatal("OnAdd must call PushFront once")
+	}
+}
+
+// Window overflow moves the window LRU into probation while main has room.
+func TestTinyLFU_WindowOverflowMovesToProbation(t *testing.T) {
+	t.Parallel()
+
+	p := New[int, int](100).New(&mockHooks[int, int]{}).(*tinyLFU[int, int])
+
+	n1 := &testNode[int, int]{k: 1}
+	n2 := &testNode[int, int]{k: 2}
+	p.OnAdd(n1)
+	if ev := p.OnAdd(n2); ev != nil { // window cap is 1
+		t.Fatalf("main has room, nothing to evict (got %v)", ev.Key())
+	}
+	if segOf(t, p, n1) != segProbation || segOf(t, p, n2) != segWindow {
+		t.Fatal("n1 must move to probation, n2 must stay in the window")
+	}
+}
+
+// A probation hit promotes to protected; protected overflow is demoted.
+func TestTinyLFU_ProbationHitPromotes(t *testing.T) {
+	t.Parallel()
+
+	p := New[int, int](10).New(&mockHooks[int, int]{}).(*tinyLFU[int, int])
+	nodes := make([]*testNode[int, int], 10)
+	for i := range nodes {
+		nodes[i] = &testNode[int, int]{k: i}
+		p.OnAdd(nodes[i])
+	}
+	// All but the last are in probation now; promote them.
+	for _, n := range nodes[:9] {
+		p.OnGet(n)
+	}
+	if p.protected.Len() != p.capProtected {
+		t.Fatalf("protected must be capped at %d, got %d", p.capProtected, p.protected.Len())
+	}
+	if segOf(t, p, nodes[8]) != segProtected {
+		t.Fatal("most recently promoted node must be protected")
+	}
+	if segOf(t, p, nodes[0]) != segProbation {
+		t.Fatal("oldest protected node must be demoted to probation")
+	}
+}
+
+// Frequently used keys survive a scan of one-hit wonders.
+func TestTinyLFU_ScanResistance(t *testing.T) {
+	t.Parallel()
+
+	s := newSim(100)
+	for round := 0; round < 5; round++ {
+		for k := 0; k < 50; k++ {
+			s.access(k)
+		}
+	}
+	for k := 1000; k < 1200; k++ { // one-hit wonders
+		s.access(k)
+	}
+	hot := 0
+	for k := 0; k < 50; k++ {
+		if _, ok := s.nodes[k]; ok {
+			hot++
+		}
+	}
+	if hot < 45 {
+		t.Fatalf("hot keys must survive a scan, only %d/50 resident", hot)
+	}
+	if len(s.nodes) > 100 {
+		t.Fatalf("resident set exceeds capacity: %d", len(s.nodes))
+	}
+}
+
+// OnRemove forgets the node in any segment.
+func TestTinyLFU_OnRemove(t *testing.T) {
+	t.Parallel()
+
+	p := New[int, int](100).New(&mockHooks[int, int]{}).(*tinyLFU[int, int])
+	n := &testNode[int, int]{k: 1}
+	p.OnAdd(n)
+	p.OnRemove(n)
+	if _, ok := p.idx[n]; ok || p.window.Len() != 0 {
+		t.Fatal("node must be removed from index and window")
+	}
+}
+
+// On a skewed (Zipf) workload mixed with scans, W-TinyLFU must beat LRU.
+func TestTinyLFU_HitRatioBeatsLRU(t *testing.T) {
+	t.Parallel()
+
+	const capacity, keys, ops = 500, 50_000, 200_000
+
+	r := rand.New(rand.NewSource(42))
+	zipf := rand.NewZipf(r, 1.01, 1, keys-1)
+	trace := make([]int, ops)
+	scan := keys
+	for i := range trace {
+		if i%4 == 0 {
+			trace[i] = scan // unique keys: a slow scan
+			scan++
+		} else {
+			trace[i] = int(zipf.Uint64())
+		}
+	}
+
+	s := newSim(capacity)
+	tlfuHits := 0
+	for _, k := range trace {
+		if s.access(k) {
+			tlfuHits++
+		}
+	}
+
+	lruHits := 0
+	l := list.New()
+	idx := make(map[int]*list.Element)
+	for _, k := range trace {
+		if el, ok := idx[k]; ok {
+			l.MoveToFront(el)
+			lruHits++
+			continue
+		}
+		idx[k] = l.PushFront(k)
+		if l.Len() > capacity {
+			delete(idx, l.Remove(l.Back()).(int))
+		}
+	}
+
+	if tlfuHits <= lruHits {
+		t.Fatalf("W-TinyLFU hits %d must exceed LRU hits %d", tlfuHits, lruHits)
+	}
+	t.Logf("hit ratio: tinylfu=%.3f lru=%.3f",
+		float64(tlfuHits)/ops, float64(lruHits)/ops)
+}
+
+// Victim drains probation first, then protected, then the window.
+func TestTinyLFU_VictimOrder(t *testing.T) {
+	t.Parallel()
+
+	p := New[int, int](100).New(&mockHooks[int, int]{}).(*tinyLFU[int, int])
+	if p.Victim() != nil {
+		t.Fatal("empty policy must not propose a victim")
+	}
+
+	n1 := &testNode[int, int]{k: 1}
+	n2 := &testNode[int, int]{k: 2}
+	n3 := &testNode[int, int]{k: 3}
+	p.OnAdd(n1)
+	if v := p.Victim(); v != n1 {
+		t.Fatalf("only the window is populated, want n1, got %v", v)
+	}
+	p.OnAdd(n2) // n1 -> probation
+	p.OnAdd(n3) // n2 -> probation
+	p.OnGet(n1) // n1 -> protected
+	if v := p.Victim(); v != n2 {
+		t.Fatalf("want probation LRU n2, got %v", v)
+	}
+	p.OnRemove(n2)
+	if v := p.Victim(); v != n1 {
+		t.Fatalf("want protected LRU n1, got %v", v)
+	}
+}
+
+// Segment names the segment of a node for inspection.
+func TestTinyLFU_Segment(t *testing.T) {
+	t.Parallel()
+
+	p := New[int, int](100).New(&mockHooks[int, int]{}).(*tinyLFU[int, int])
+
+	n1 := &testNode[int, int]{k: 1}
+	n2 := &testNode[int, int]{k: 2}
+	p.OnAdd(n1)
+	if s := p.Segment(n1); s != "window" {
+		t.Fatalf("want window, got %q", s)
+	}
+	p.OnAdd(n2) // window cap is 1: n1 moves to probation
+	if s := p.Segment(n1); s != "probation" {
+		t.Fatalf("want probation, got %q", s)
+	}
+	p.OnGet(n1)
+	if s := p.Segment(n1); s != "protected" {
+		t.Fatalf("want protected, got %q", s)
+	}
+	p.OnRemove(n1)
+	if s := p.Segment(n1); s != "" {
+		t.Fatalf("removed node must have no segment, got %q", s)
+	}
+}
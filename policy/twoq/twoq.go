@@ -11,7 +11,10 @@ import (
 //
 // Resident queues:
 //   • A1in (younger queue) — its own list + index by Node; admits first-time entries
-//   • Am   (mature queue)  — nodes not present in inIdx; ordering is driven by shard hooks
+//   • Am   (mature queue)  — its own list + index by Node; entries seen at least twice
+//
+// The shard list still receives every promotion (global recency order), but
+// limit-driven evictions are chosen by Victim from the policy's own queues.
 //
 // Ghost A1out: keys only (no values), tracks recently evicted A1in keys to give them
 // a second chance (bypass A1in on re-admission).
@@ -28,6 +31,10 @@ type twoQ[K comparable, V any] struct {
 	// Fast membership check for "is node in A1in?"
 	inIdx map[policy.Node[K, V]]*list.Element // element.Value is policy.Node[K,V]
 
+	// Am: MRU at Front() -> LRU at Back()
+	amList *list.List
+	amIdx  map[policy.Node[K, V]]*list.Element // element.Value is policy.Node[K,V]
+
 	// A1out (ghosts): keys only, MRU at Front() -> LRU at Back()
 	ghostList *list.List
 	ghostIdx  map[K]*list.Element // key -> element in ghostList (element.Value is K)
@@ -58,6 +65,8 @@ func (p twoQPolicy[K, V]) New(h policy.Hooks[K, V]) policy.ShardPolicy[K, V] {
 		capGhost:  p.capGhost,
 		inList:    list.New(),
 		inIdx:     make(map[policy.Node[K, V]]*list.Element),
+		amList:    list.New(),
+		amIdx:     make(map[policy.Node[K, V]]*list.Element),
 		ghostList: list.New(),
 		ghostIdx:  make(map[K]*list.Element),
 	}
@@ -74,7 +83,8 @@ func (q *twoQ[K, V]) OnAdd(n policy.Node[K, V]) (evict policy.Node[K, V]) {
 		// Second chance: promote from ghosts directly into Am (skip A1in).
 		q.ghostList.Remove(ge)
 		delete(q.ghostIdx, k)
-		q.h.PushFront(n) // MRU in shard list (Am)
+		q.h.PushFront(n) // MRU in shard list
+		q.amIdx[n] = q.amList.PushFront(n)
 		return nil
 	}
 
@@ -91,12 +101,15 @@ func (q *twoQ[K, V]) OnAdd(n policy.Node[K, V]) (evict policy.Node[K, V]) {
 	return nil
 }
 
-// OnGet: if the node was in A1in, remove it from A1in (promotion to Am),
-// then move it to MRU in the shard list.
+// OnGet: if the node was in A1in, move it to Am (promotion); if it was
+// already in Am, move it to Am's MRU. Either way it becomes MRU in the shard list.
 func (q *twoQ[K, V]) OnGet(n policy.Node[K, V]) {
 	if el, ok := q.inIdx[n]; ok {
 		q.inList.Remove(el)
 		delete(q.inIdx, n)
+		q.amIdx[n] = q.amList.PushFront(n)
+	} else if el, ok := q.amIdx[n]; ok {
+		q.amList.MoveToFront(el)
 	}
 	q.h.MoveToFront(n)
 }
@@ -104,10 +117,42 @@ func (q *twoQ[K, V]) OnGet(n policy.Node[K, V]) {
 // OnUpdate follows OnGet semantics (updates count as recent use).
 func (q *twoQ[K, V]) OnUpdate(n policy.Node[K, V]) { q.OnGet(n) }
 
+// Victim follows the 2Q "reclaim" rule: once A1in has reached its share of
+// capIn entries (or Am is empty) evict the LRU of A1in, otherwise the LRU of
+// Am. OnAdd keeps A1in at most capIn long, so the check must be inclusive.
+// This keeps the A1in/Am split intact when entry or cost limits are hit.
+func (q *twoQ[K, V]) Victim() policy.Node[K, V] {
+	if q.inList.Len() >= q.capIn || q.amList.Len() == 0 {
+		if el := q.inList.Back(); el != nil {
+			return el.Value.(policy.Node[K, V])
+		}
+	}
+	if el := q.amList.Back(); el != nil {
+		return el.Value.(policy.Node[K, V])
+	}
+	return nil
+}
+
+// Segment reports the resident queue of n: "A1in" or "Am".
+func (q *twoQ[K, V]) Segment(n policy.Node[K, V]) string {
+	if _, ok := q.inIdx[n]; ok {
+		return "A1in"
+	}
+	if _, ok := q.amIdx[n]; ok {
+		return "Am"
+	}
+	return ""
+}
+
 // OnRemove:
 //   • If the node was in A1in, add its key to ghosts (A1out), respecting capGhost.
 //   • Removals from Am do NOT populate ghosts.
 func (q *twoQ[K, V]) OnRemove(n policy.Node[K, V]) {
+	if el, ok := q.amIdx[n]; ok {
+		q.amList.Remove(el)
+		delete(q.amIdx, n)
+		return
+	}
 	if el, ok := q.inIdx[n]; ok {
 		// Remove from A1in tracking.
 		q.inList.Remove(el)
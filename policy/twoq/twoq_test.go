@@ -140,3 +140,90 @@ func TestTwoQ_GetPromotesFromA1inToAm(t *testing.T) {
 		t.Fatalf("OnGet must call MoveToFront once")
 	}
 }
+
+// Victim prefers the LRU of Am while A1in is within capIn,
+// and falls back to A1in when Am is empty.
+func TestTwoQ_VictimRespectsQueues(t *testing.T) {
+	t.Parallel()
+
+	h := &mockHooks[string, int]{}
+	p := New[string, int](2, 2).New(h).(*twoQ[string, int])
+
+	if p.Victim() != nil {
+		t.Fatal("empty policy must not propose a victim")
+	}
+
+	n1 := &testNode[string, int]{k: "a", v: 1}
+	n2 := &testNode[string, int]{k: "b", v: 2}
+	p.OnAdd(n1)
+	if v := p.Victim(); v != n1 {
+		t.Fatalf("with Am empty, Victim must be A1in LRU, got %v", v)
+	}
+
+	p.OnGet(n1) // a -> Am
+	p.OnAdd(n2) // b -> A1in
+	if v := p.Victim(); v != n1 {
+		t.Fatalf("with A1in within capIn, Victim must be Am LRU, got %v", v)
+	}
+
+	p.OnRemove(n1)
+	if _, ok := p.amIdx[n1]; ok {
+		t.Fatal("n1 must be removed from Am")
+	}
+	if _, ok := p.ghostIdx["a"]; ok {
+		t.Fatal("removal from Am must not create a ghost")
+	}
+	if v := p.Victim(); v != n2 {
+		t.Fatalf("after Am drained, Victim must be A1in LRU, got %v", v)
+	}
+}
+
+// Segment names the resident queue of a node.
+func TestTwoQ_Segment(t *testing.T) {
+	t.Parallel()
+
+	p := New[string, int](2, 2).New(&mockHooks[string, int]{}).(*twoQ[string, int])
+
+	n := &testNode[string, int]{k: "a", v: 1}
+	if s := p.Segment(n); s != "" {
+		t.Fatalf("untracked node must have no segment, got %q", s)
+	}
+	p.OnAdd(n)
+	if s := p.Segment(n); s != "A1in" {
+		t.Fatalf("want A1in, got %q", s)
+	}
+	p.OnGet(n)
+	if s := p.Segment(n); s != "Am" {
+		t.Fatalf("want Am, got %q", s)
+	}
+}
+
+// With both queues populated, Victim reclaims A1in once it holds capIn
+// entries and only falls back to Am while A1in is below its share.
+func TestTwoQ_VictimReclaimsFullA1in(t *testing.T) {
+	t.Parallel()
+
+	p := New[string, int](2, 2).New(&mockHooks[string, int]{}).(*twoQ[string, int])
+
+	a := &testNode[string, int]{k: "a"}
+	b := &testNode[string, int]{k: "b"}
+	c := &testNode[string, int]{k: "c"}
+	d := &testNode[string, int]{k: "d"}
+	p.OnAdd(a)
+	p.OnGet(a) // a -> Am
+	p.OnAdd(b)
+	p.OnGet(b) // b -> Am
+	p.OnAdd(c) // A1in: [c]
+	if v := p.Victim(); v != a {
+		t.Fatalf("A1in below capIn: Victim must be Am LRU, got %v", v.Key())
+	}
+
+	p.OnAdd(d) // A1in: [d c], at capIn
+	if v := p.Victim(); v != c {
+		t.Fatalf("A1in at capIn: Victim must be A1in LRU, got %v", v.Key())
+	}
+	p.OnRemove(c)
+	if v := p.Victim(); v != a {
+		t.Fatalf("A1in back below capIn: Victim must be Am LRU, got %v", v.Key())
+	}
+}